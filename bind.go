@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"mime"
+	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/devfeel/dotweb/framework/reflects"
 )
@@ -12,19 +16,47 @@ import (
 const (
 	defaultTagName = "form"
 	jsonTagName    = "json"
+	pathTagName    = "path"
+	queryTagName   = "query"
+	headerTagName  = "header"
+	cookieTagName  = "cookie"
 )
 
+// ErrUnsupportedMediaType is returned by Bind when no decoder is registered for request Content-Type,
+// DefaultHTTPErrorHandler reply it with 415
+var ErrUnsupportedMediaType = errors.New("request unsupported MediaType")
+
 type (
 	// Binder is the interface that wraps the Bind method.
 	Binder interface {
 		Bind(interface{}, Context) error
 		BindJsonBody(interface{}, Context) error
+		// RegisterDecoder registers the decoder used by Bind for request body with the media type
+		RegisterDecoder(mime string, decoder Decoder)
+	}
+
+	// Decoder decode request body to i
+	Decoder interface {
+		Decode(body []byte, i interface{}) error
 	}
 
-	binder struct{}
+	// DecoderFunc is an adapter to allow the use of ordinary functions as Decoder
+	DecoderFunc func(body []byte, i interface{}) error
+
+	binder struct {
+		decoders     map[string]Decoder
+		decodersLock *sync.RWMutex
+	}
 )
 
-// Bind decode req.Body or form-value to struct
+// Decode calls f(body, i)
+func (f DecoderFunc) Decode(body []byte, i interface{}) error {
+	return f(body, i)
+}
+
+// Bind decode req.Body or form-value to struct,
+// then bind fields with source tags: `query:"page"`, `header:"X-Tenant"`, `cookie:"sid"` and `path:"id"`.
+// Values from later source override the former: body < query < header < cookie < path
 func (b *binder) Bind(i interface{}, ctx Context) (err error) {
 	req := ctx.Request()
 	ctype := req.Header.Get(HeaderContentType)
@@ -32,16 +64,9 @@ func (b *binder) Bind(i interface{}, ctx Context) (err error) {
 		err = errors.New("request body can't be empty")
 		return err
 	}
-	err = errors.New("request unsupported MediaType -> " + ctype)
-	switch {
-	case strings.HasPrefix(ctype, MIMEApplicationJSON):
-		err = json.Unmarshal(ctx.Request().PostBody(), i)
-	case strings.HasPrefix(ctype, MIMEApplicationXML):
-		err = xml.Unmarshal(ctx.Request().PostBody(), i)
-	// case strings.HasPrefix(ctype, MIMEApplicationForm), strings.HasPrefix(ctype, MIMEMultipartForm),
-	// 	strings.HasPrefix(ctype, MIMETextHTML):
-	// 	err = reflects.ConvertMapToStruct(defaultTagName, i, ctx.FormValues())
-	default:
+	mediaType := parseMediaType(ctype)
+	switch mediaType {
+	case "", MIMEApplicationForm, MIMEMultipartForm:
 		// check is use json tag, fixed for issue #91
 		tagName := defaultTagName
 		if ctx.HttpServer().ServerConfig().EnabledBindUseJsonTag {
@@ -49,8 +74,49 @@ func (b *binder) Bind(i interface{}, ctx Context) (err error) {
 		}
 		// no check content type for fixed issue #6
 		err = reflects.ConvertMapToStruct(tagName, i, ctx.Request().FormValues())
+	default:
+		decoder := b.getDecoder(mediaType)
+		if decoder == nil {
+			return fmt.Errorf("%w -> %s", ErrUnsupportedMediaType, ctype)
+		}
+		err = decoder.Decode(ctx.Request().PostBody(), i)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return b.bindSources(i, ctx)
+}
+
+// bindSources bind fields with source tags in precedence order
+func (b *binder) bindSources(i interface{}, ctx Context) error {
+	req := ctx.Request()
+	if req.URL != nil {
+		if err := reflects.ConvertTaggedMapToStruct(queryTagName, i, req.URL.Query(), nil); err != nil {
+			return err
+		}
+	}
+	if err := reflects.ConvertTaggedMapToStruct(headerTagName, i, req.Header, http.CanonicalHeaderKey); err != nil {
+		return err
+	}
+	if cookies := req.Cookies(); len(cookies) > 0 {
+		values := make(map[string][]string, len(cookies))
+		for _, c := range cookies {
+			values[c.Name] = append(values[c.Name], c.Value)
+		}
+		if err := reflects.ConvertTaggedMapToStruct(cookieTagName, i, values, nil); err != nil {
+			return err
+		}
+	}
+	if params := ctx.RouterParams(); len(params) > 0 {
+		values := make(map[string][]string, len(params))
+		for _, p := range params {
+			values[p.Key] = append(values[p.Key], p.Value)
+		}
+		if err := reflects.ConvertTaggedMapToStruct(pathTagName, i, values, nil); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // BindJsonBody default use json decode req.Body to struct
@@ -63,6 +129,47 @@ func (b *binder) BindJsonBody(i interface{}, ctx Context) (err error) {
 	return err
 }
 
+// RegisterDecoder registers the decoder used by Bind for request body with the media type,
+// parameters in mime like charset are ignored, the decoder with same media type is replaced.
+// e.g. RegisterDecoder("application/x-msgpack", DecoderFunc(msgpack.Unmarshal))
+func (b *binder) RegisterDecoder(mime string, decoder Decoder) {
+	mediaType := parseMediaType(mime)
+	if mediaType == "" || decoder == nil {
+		panic("binder: decoder mime and decoder must not be empty")
+	}
+	b.decodersLock.Lock()
+	b.decoders[mediaType] = decoder
+	b.decodersLock.Unlock()
+}
+
+// getDecoder return decoder registered for media type,
+// type with structured syntax suffix like "application/problem+json" fall back to "application/json"
+func (b *binder) getDecoder(mediaType string) Decoder {
+	b.decodersLock.RLock()
+	defer b.decodersLock.RUnlock()
+	if decoder, exists := b.decoders[mediaType]; exists {
+		return decoder
+	}
+	if index := strings.LastIndex(mediaType, "+"); index >= 0 {
+		return b.decoders["application/"+mediaType[index+1:]]
+	}
+	return nil
+}
+
+// parseMediaType return lower media type without parameters
+func parseMediaType(ctype string) string {
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		// keep the part before parameters for malformed parameters
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(ctype, ";")[0]))
+	}
+	return mediaType
+}
+
 func newBinder() *binder {
-	return &binder{}
+	b := &binder{decoders: make(map[string]Decoder), decodersLock: new(sync.RWMutex)}
+	b.RegisterDecoder(MIMEApplicationJSON, DecoderFunc(json.Unmarshal))
+	b.RegisterDecoder(MIMEApplicationXML, DecoderFunc(xml.Unmarshal))
+	b.RegisterDecoder("text/xml", DecoderFunc(xml.Unmarshal))
+	return b
 }
@@ -1,9 +1,12 @@
 package dotweb
 
 import (
-	"github.com/devfeel/dotweb/test"
-
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/devfeel/dotweb/test"
 )
 
 type Person struct {
@@ -342,3 +345,86 @@ func TestBinder_Bind_ContentTypeNull(t *testing.T) {
 	// check error must nil?
 	test.Nil(t, err)
 }
+
+type testSourceUser struct {
+	ID      int      `json:"id" path:"id"`
+	Name    string   `json:"name"`
+	Page    int      `query:"page"`
+	Tags    []string `query:"tag"`
+	Tenant  string   `header:"x-tenant"`
+	Session string   `cookie:"sid"`
+}
+
+func TestBinder_Bind_sources(t *testing.T) {
+	app := New()
+	app.HttpServer.POST("/user/:id", func(ctx Context) error {
+		user := &testSourceUser{}
+		if err := ctx.Bind(user); err != nil {
+			return err
+		}
+		return ctx.WriteJson(user)
+	})
+	prepareTestApp(app)
+
+	// path param override id in body
+	req := httptest.NewRequest("POST", "/user/3?page=2&tag=a&tag=b", strings.NewReader(`{"id":1,"name":"dotweb"}`))
+	req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+	w := httptest.NewRecorder()
+	app.HttpServer.ServeHTTP(w, req)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, `{"id":3,"name":"dotweb","Page":2,"Tags":["a","b"],"Tenant":"acme","Session":"s1"}`, w.Body.String())
+}
+
+type testBindCategory struct {
+	Name   string            `json:"name"`
+	Parent *testBindCategory `json:"parent"`
+}
+
+func TestBinder_Bind_recursive(t *testing.T) {
+	app := New()
+	app.HttpServer.POST("/category", func(ctx Context) error {
+		category := &testBindCategory{}
+		if err := ctx.Bind(category); err != nil {
+			return err
+		}
+		return ctx.WriteString(category.Name + "/" + category.Parent.Name)
+	})
+	prepareTestApp(app)
+
+	req := httptest.NewRequest("POST", "/category", strings.NewReader(`{"name":"phone","parent":{"name":"digital"}}`))
+	req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	w := httptest.NewRecorder()
+	app.HttpServer.ServeHTTP(w, req)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "phone/digital", w.Body.String())
+}
+
+func TestBinder_RegisterDecoder(t *testing.T) {
+	app := New()
+	app.HttpServer.Binder().RegisterDecoder("application/x-test", DecoderFunc(func(body []byte, i interface{}) error {
+		i.(*testSourceUser).Name = "x-test:" + string(body)
+		return nil
+	}))
+	app.HttpServer.POST("/user", func(ctx Context) error {
+		user := &testSourceUser{}
+		if err := ctx.Bind(user); err != nil {
+			return err
+		}
+		return ctx.WriteString(user.Name)
+	})
+	prepareTestApp(app)
+
+	post := func(ctype, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/user", strings.NewReader(body))
+		req.Header.Set(HeaderContentType, ctype)
+		w := httptest.NewRecorder()
+		app.HttpServer.ServeHTTP(w, req)
+		return w
+	}
+	test.Equal(t, "x-test:data", post("Application/X-Test; charset=utf-8", "data").Body.String())
+	test.Equal(t, "dotweb", post("application/vnd.user+json", `{"name":"dotweb"}`).Body.String())
+	test.Equal(t, "form", post(MIMEApplicationForm, "Name=form").Body.String())
+	test.Equal(t, http.StatusUnsupportedMediaType, post("text/csv", "a,b").Code)
+}
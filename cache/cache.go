@@ -30,6 +30,14 @@ type Cache interface {
 	ClearAll() error
 }
 
+// TTLCounter is implemented by caches which can set ttl of counter when it is created,
+// so counters of time windows are removed after expired
+type TTLCounter interface {
+	// IncrWithTTL increases int64-type value by given key as a counter,
+	// if key not exist or expired, set value with zero and ttl (second) before increase
+	IncrWithTTL(key string, ttl int64) (int64, error)
+}
+
 // NewRuntimeCache new runtime cache
 func NewRuntimeCache() Cache {
 	return runtime.NewRuntimeCache()
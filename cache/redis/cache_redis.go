@@ -39,6 +39,13 @@ func (ca *RedisCache) Incr(key string) (int64, error) {
 	return int64(val), nil
 }
 
+// IncrWithTTL increase int64 counter in redis cache, the ttl is set when counter is created.
+// INCR and EXPIRE run in one lua script, so the counter never lives without ttl.
+func (ca *RedisCache) IncrWithTTL(key string, ttl int64) (int64, error) {
+	redisClient := redisutil.GetDefaultRedisClient(ca.serverURL)
+	return redisClient.IncrWithExpire(key, ttl)
+}
+
 // Decr decrease counter in redis cache.
 func (ca *RedisCache) Decr(key string) (int64, error) {
 	redisClient := redisutil.GetDefaultRedisClient(ca.serverURL)
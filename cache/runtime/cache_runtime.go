@@ -130,8 +130,23 @@ func (ca *RuntimeCache) Incr(key string) (int64, error) {
 		// reload
 		itemObj, _ = ca.items.Load(key)
 	}
+	return incrItem(itemObj.(*RuntimeItem))
+}
 
-	item := itemObj.(*RuntimeItem)
+// IncrWithTTL increase int64 counter in runtime cache, the ttl is set when counter is created.
+func (ca *RuntimeCache) IncrWithTTL(key string, ttl int64) (int64, error) {
+	ca.Lock()
+	defer ca.Unlock()
+	itemObj, ok := ca.items.Load(key)
+	if !ok || itemObj.(*RuntimeItem).isExpire() {
+		ca.initValue(key, ZeroInt64, ttl)
+		itemObj, _ = ca.items.Load(key)
+	}
+	return incrItem(itemObj.(*RuntimeItem))
+}
+
+// incrItem increase the value of item, cache lock must be held
+func incrItem(item *RuntimeItem) (int64, error) {
 	switch item.value.(type) {
 	case int:
 		item.value = item.value.(int) + 1
@@ -158,6 +158,20 @@ func TestRuntimeCache_Incr(t *testing.T) {
 	test.Equal(t, 100, value)
 }
 
+func TestRuntimeCache_IncrWithTTL(t *testing.T) {
+	cache := NewRuntimeCache()
+	value, err := cache.IncrWithTTL(TESTCacheKey, 1)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), value)
+	value, _ = cache.IncrWithTTL(TESTCacheKey, 1)
+	test.Equal(t, int64(2), value)
+
+	// counter restart after expired
+	time.Sleep(1100 * time.Millisecond)
+	value, _ = cache.IncrWithTTL(TESTCacheKey, 1)
+	test.Equal(t, int64(1), value)
+}
+
 func TestRuntimeCache_Decr(t *testing.T) {
 	cache := NewRuntimeCache()
 	var wg sync.WaitGroup
@@ -42,9 +42,11 @@ type (
 		EnabledGzip                  bool   `xml:"enabledgzip,attr"`            // enable gzip
 		EnabledAutoHEAD              bool   `xml:"enabledautohead,attr"`        // ehanble HEAD routing, default is false, will add HEAD routing for all routes except for websocket and HEAD
 		EnabledAutoOPTIONS           bool   `xml:"-"`                           // enable OPTIONS routing, default is false, will add OPTIONS routing for all routes except for websocket and OPTIONS
+		EnabledAutoCORSPreflight     bool   `xml:"enabledautocorspreflight,attr"` // CORS preflight reply of CorsMiddleware use the allowed methods computed from the route, default is false
 		EnabledRedirectTrailingSlash bool   `xml:"enabledredirecttrailingslash,attr"` // enable automatic redirection for URLs with trailing slash, default is false to match net/http behavior
 		EnabledIgnoreFavicon         bool   `xml:"enabledignorefavicon,attr"`  // ignore favicon.ico request, return empty reponse if set
 		EnabledBindUseJsonTag        bool   `xml:"enabledbindusejsontag,attr"` // allow Bind to use JSON tag, default is false, Bind will use json tag automatically and ignore form tag
+		EnabledBindValidate          bool   `xml:"enabledbindvalidate,attr"`   // validate the struct by Validator after Bind, default is false
 		EnabledStaticFileMiddleware  bool   `xml:"-"` // The flag which enabled or disabled middleware for static-file route
 		Port                        int    `xml:"port,attr"`                     // port
 		EnabledTLS                  bool   `xml:"enabledtls,attr"`               // enable TLS
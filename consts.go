@@ -34,6 +34,7 @@ const (
 const (
 	MIMEApplicationJSON                  = "application/json"
 	MIMEApplicationJSONCharsetUTF8       = MIMEApplicationJSON + "; " + CharsetUTF8
+	MIMEApplicationProblemJSON           = "application/problem+json"
 	MIMEApplicationJavaScript            = "application/javascript"
 	MIMEApplicationJavaScriptCharsetUTF8 = MIMEApplicationJavaScript + "; " + CharsetUTF8
 	MIMEApplicationXML                   = "application/xml"
@@ -41,16 +42,23 @@ const (
 	MIMEApplicationForm                  = "application/x-www-form-urlencoded"
 	MIMEApplicationProtobuf              = "application/protobuf"
 	MIMEApplicationMsgpack               = "application/msgpack"
+	MIMEApplicationYAML                  = "application/yaml"
+	MIMEApplicationNDJSON                = "application/x-ndjson"
 	MIMETextHTML                         = "text/html"
 	MIMETextHTMLCharsetUTF8              = MIMETextHTML + "; " + CharsetUTF8
 	MIMETextPlain                        = "text/plain"
 	MIMETextPlainCharsetUTF8             = MIMETextPlain + "; " + CharsetUTF8
+	MIMETextEventStream                  = "text/event-stream"
+	MIMETextCSV                          = "text/csv"
+	MIMETextCSVCharsetUTF8               = MIMETextCSV + "; " + CharsetUTF8
 	MIMEMultipartForm                    = "multipart/form-data"
 	MIMEOctetStream                      = "application/octet-stream"
 )
 
 // Headers
 const (
+	HeaderAccept                        = "Accept"
+	HeaderLastEventID                   = "Last-Event-ID"
 	HeaderAcceptEncoding                = "Accept-Encoding"
 	HeaderAllow                         = "Allow"
 	HeaderAuthorization                 = "Authorization"
@@ -64,6 +72,12 @@ const (
 	HeaderLastModified                  = "Last-Modified"
 	HeaderLocation                      = "Location"
 	HeaderUpgrade                       = "Upgrade"
+	HeaderConnection                    = "Connection"
+	HeaderSecWebSocketKey               = "Sec-WebSocket-Key"
+	HeaderSecWebSocketAccept            = "Sec-WebSocket-Accept"
+	HeaderSecWebSocketVersion           = "Sec-WebSocket-Version"
+	HeaderSecWebSocketProtocol          = "Sec-WebSocket-Protocol"
+	HeaderSecWebSocketExtensions        = "Sec-WebSocket-Extensions"
 	HeaderVary                          = "Vary"
 	HeaderWWWAuthenticate               = "WWW-Authenticate"
 	HeaderXRequestedWith                = "X-Requested-With"
@@ -72,6 +86,12 @@ const (
 	HeaderXForwardedFor                 = "X-Forwarded-For"
 	HeaderXRealIP                       = "X-Real-IP"
 	HeaderServer                        = "Server"
+	HeaderRetryAfter                    = "Retry-After"
+	HeaderAge                           = "Age"
+	HeaderXCache                        = "X-Cache"
+	HeaderRateLimitLimit                = "RateLimit-Limit"
+	HeaderRateLimitRemaining            = "RateLimit-Remaining"
+	HeaderRateLimitReset                = "RateLimit-Reset"
 	HeaderOrigin                        = "Origin"
 	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
 	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"golang.org/x/net/websocket"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -71,9 +71,12 @@ type (
 		Inline(file string, name string) error
 		Bind(i interface{}) error
 		BindJsonBody(i interface{}) error
+		BindJsonStream(i interface{}) error
+		ReadNDJSON(fn func(raw json.RawMessage) error) error
 		// Validate validates provided `i`. It is usually called after `Context#Bind()`.
 		Validate(i interface{}) error
 		GetRouterName(key string) string
+		URLFor(name string, kv ...interface{}) (string, error)
 		RemoteIP() string
 		SetCookieValue(name, value string, maxAge int)
 		SetCookie(cookie *http.Cookie)
@@ -96,6 +99,11 @@ type (
 		WriteJsonBlobC(code int, b []byte) error
 		WriteJsonp(callback string, i interface{}) error
 		WriteJsonpBlob(callback string, b []byte) error
+		Negotiate(code int, i interface{}) error
+		StreamJSONArray(iter StreamIterator) error
+		StreamNDJSON(ch <-chan interface{}) error
+		StreamCSV(header []string, rows <-chan []string) error
+		SSE() (*SSEWriter, error)
 
 		//inner func
 		getMiddlewareStep() string
@@ -106,6 +114,7 @@ type (
 		setRouterParams(params Params)
 		setRouterNode(node RouterNode)
 		setHandler(handler HttpHandle)
+		setWebSocket(ws *WebSocket)
 		getCancel() context.CancelFunc
 		setCancel(cancel context.CancelFunc)
 	}
@@ -121,6 +130,7 @@ type (
 		response       *Response
 		webSocket      *WebSocket
 		hijackConn     *HijackConn
+		sse            *SSEWriter
 		isWebSocket    bool
 		isHijack       bool
 		isEnd          bool // indicating whether the current process should be terminated
@@ -133,10 +143,6 @@ type (
 		tools          *Tools
 	}
 
-	WebSocket struct {
-		Conn *websocket.Conn
-	}
-
 	// hijack conn
 	HijackConn struct {
 		ReadWriter *bufio.ReadWriter
@@ -186,12 +192,19 @@ func (ctx *HttpContext) release() {
 	ctx.viewData = nil
 	ctx.sessionID = ""
 	ctx.handler = nil
+	ctx.context = nil
+	ctx.cancel = nil
+	ctx.sse = nil
 	ctx.Items().Remove(ItemKeyHandleStartTime)
 	ctx.Items().Remove(ItemKeyHandleDuration)
 }
 
 // Context return context.Context
+// if not set by SetTimeoutContext or WithContext, return the context of http.Request
 func (ctx *HttpContext) Context() context.Context {
+	if ctx.context == nil && ctx.request != nil && ctx.request.Request != nil {
+		return ctx.request.Request.Context()
+	}
 	return ctx.context
 }
 
@@ -465,17 +478,74 @@ func (ctx *HttpContext) contentDisposition(file, name, dispositionType string) (
 }
 
 // Bind decode req.Body or form-value to struct
+// if EnabledBindValidate is set, the struct is validated after decoded
 func (ctx *HttpContext) Bind(i interface{}) error {
-	return ctx.httpServer.Binder().Bind(i, ctx)
+	if err := ctx.httpServer.Binder().Bind(i, ctx); err != nil {
+		return err
+	}
+	return ctx.validateAfterBind(i)
 }
 
 // BindJsonBody default use json decode req.Body to struct
+// if EnabledBindValidate is set, the struct is validated after decoded
 func (ctx *HttpContext) BindJsonBody(i interface{}) error {
-	return ctx.httpServer.Binder().BindJsonBody(i, ctx)
+	if err := ctx.httpServer.Binder().BindJsonBody(i, ctx); err != nil {
+		return err
+	}
+	return ctx.validateAfterBind(i)
+}
+
+// BindJsonStream decode req.Body to struct with json.Decoder without reading the whole body into memory,
+// body size, nesting depth and element count are limited by HttpServer.JsonStreamLimit.
+// if EnabledBindValidate is set, the struct is validated after decoded
+func (ctx *HttpContext) BindJsonStream(i interface{}) error {
+	stream := ctx.newJsonStream(true)
+	if err := stream.decode(i); err != nil {
+		return err
+	}
+	if stream.dec.More() {
+		return ErrJsonTrailingData
+	}
+	if stream.lr.err != nil {
+		return stream.lr.err
+	}
+	return ctx.validateAfterBind(i)
+}
+
+// ReadNDJSON read newline delimited json records from req.Body one by one and call fn with each record,
+// it stops when fn returns error or Context.Context() is done.
+// body size and nesting depth are limited by HttpServer.JsonStreamLimit, MaxElements limit the count of records
+func (ctx *HttpContext) ReadNDJSON(fn func(raw json.RawMessage) error) error {
+	stream := ctx.newJsonStream(false)
+	for count := 0; ; count++ {
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+		var raw json.RawMessage
+		if err := stream.decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if stream.limit.MaxElements > 0 && count >= stream.limit.MaxElements {
+			return ErrJsonTooManyElements
+		}
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+}
+
+func (ctx *HttpContext) validateAfterBind(i interface{}) error {
+	if !ctx.httpServer.ServerConfig().EnabledBindValidate {
+		return nil
+	}
+	return ctx.Validate(i)
 }
 
-// Validate validates data with HttpServer::Validator
-// We will implementing inner validator on next version
+// Validate validates data with HttpServer::Validator, return ErrValidatorNotRegistered if it is nil,
+// set HttpServer.Validator to NewValidator() to use the built-in DefaultValidator
 func (ctx *HttpContext) Validate(i interface{}) error {
 	if ctx.httpServer.Validator == nil {
 		return ErrValidatorNotRegistered
@@ -488,6 +558,42 @@ func (ctx *HttpContext) GetRouterName(key string) string {
 	return ctx.routerParams.ByName(key)
 }
 
+// URLFor build the url of the route registered with the given name by RouterNode.Name
+// kv are param key & value pairs, pairs which are not route params are added to query string
+// simple demo:ctx.URLFor("user.show", "id", 1, "tab", "profile") => /user/1?tab=profile
+func (ctx *HttpContext) URLFor(name string, kv ...interface{}) (string, error) {
+	if len(kv)%2 != 0 {
+		return "", errors.New("URLFor need key & value pairs")
+	}
+	r, ok := ctx.HttpServer().Router().(*router)
+	if !ok {
+		return "", errors.New("URLFor not supported by current router")
+	}
+	params := make(map[string]string, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		params[fmt.Sprint(kv[i])] = fmt.Sprint(kv[i+1])
+	}
+	used := make(map[string]struct{}, len(params))
+	path, err := r.buildURL(name, func(index int, key string) (string, bool) {
+		v, exists := params[key]
+		used[key] = struct{}{}
+		return v, exists
+	})
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	for k, v := range params {
+		if _, exists := used[k]; !exists {
+			query.Set(k, v)
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
 // RemoteIP return user IP address
 func (ctx *HttpContext) RemoteIP() string {
 	return ctx.request.RemoteIP()
@@ -641,6 +747,22 @@ func (ctx *HttpContext) WriteJsonBlobC(code int, b []byte) error {
 	return ctx.WriteBlobC(code, MIMEApplicationJSONCharsetUTF8, b)
 }
 
+// Negotiate write (httpCode, data) to response with the encoder which matches request Accept best,
+// return ErrNotAcceptable if no encoder is acceptable.
+// Vary: Accept is always set, encoders are registered by HttpServer.RegisterEncoder
+func (ctx *HttpContext) Negotiate(code int, i interface{}) error {
+	addVary(ctx.response.Header(), HeaderAccept)
+	entry := ctx.httpServer.encoders.match(ctx.request.Header.Get(HeaderAccept))
+	if entry == nil {
+		return ErrNotAcceptable
+	}
+	b, err := entry.encoder.Encode(i)
+	if err != nil {
+		return err
+	}
+	return ctx.WriteBlobC(code, entry.contentType, b)
+}
+
 // WriteJsonp write jsonp string to response
 func (ctx *HttpContext) WriteJsonp(callback string, i interface{}) error {
 	b, err := json.Marshal(i)
@@ -707,30 +829,17 @@ func (ctx *HttpContext) getCancel() context.CancelFunc {
 	return ctx.cancel
 }
 
+// setWebSocket set the upgraded websocket and mark the context as websocket mode
+func (ctx *HttpContext) setWebSocket(ws *WebSocket) {
+	ctx.webSocket = ws
+	ctx.isWebSocket = true
+}
+
 // setCancel
 func (ctx *HttpContext) setCancel(cancel context.CancelFunc) {
 	ctx.cancel = cancel
 }
 
-//************* WebSocket public func **********************
-
-// Request get http request
-func (ws *WebSocket) Request() *http.Request {
-	return ws.Conn.Request()
-}
-
-// SendMessage send message from websocket.conn
-func (ws *WebSocket) SendMessage(msg string) error {
-	return websocket.Message.Send(ws.Conn, msg)
-}
-
-// ReadMessage read message from websocket.conn
-func (ws *WebSocket) ReadMessage() (string, error) {
-	str := ""
-	err := websocket.Message.Receive(ws.Conn, &str)
-	return str, err
-}
-
 //************* HijackConn public func **********************
 
 // WriteString hjiack conn write string
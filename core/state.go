@@ -33,6 +33,9 @@ func NewServerStateInfo() *ServerStateInfo {
 		DetailErrorPageData:  NewItemMap(),
 		DetailErrorData:      NewItemMap(),
 		DetailHTTPCodeData:   NewItemMap(),
+		DetailErrorCodeData:  NewItemMap(),
+		RouteLimitData:       NewItemMap(),
+		HubData:              NewItemMap(),
 		dataChan_Request:     make(chan *RequestInfo, 2000),
 		dataChan_Error:       make(chan *ErrorInfo, 1000),
 		infoPool: &pool{
@@ -81,6 +84,10 @@ type ServerStateInfo struct {
 	TotalRequestCount        uint64
 	// active request count
 	CurrentRequestCount uint64
+	// open Server-Sent Events stream count
+	CurrentSSECount uint64
+	// open websocket connection count
+	CurrentWebSocketCount uint64
 	// request statistics per minute
 	IntervalRequestData *ItemMap
 	// detailed request statistics, the key is url without parameters
@@ -94,6 +101,12 @@ type ServerStateInfo struct {
 	DetailErrorData *ItemMap
 	// detailed reponse statistics of http code, the key is HttpCode, e.g. 200, 500 etc.
 	DetailHTTPCodeData *ItemMap
+	// detailed error statistics of http status, the key is status of error, e.g. 404, 500 etc.
+	DetailErrorCodeData *ItemMap
+	// route limit statistics, the key is limit name and route path, e.g. timeout:/api/upload
+	RouteLimitData *ItemMap
+	// websocket hub statistics, the key is hub name and the value is connection count
+	HubData *ItemMap
 
 	dataChan_Request chan *RequestInfo
 	dataChan_Error   chan *ErrorInfo
@@ -117,6 +130,10 @@ func (state *ServerStateInfo) ShowHtmlDataRaw(version, globalUniqueId string) st
 	data += "<br>"
 	data += "CurrentRequestCount : " + strconv.FormatUint(state.CurrentRequestCount, 10)
 	data += "<br>"
+	data += "CurrentSSECount : " + strconv.FormatUint(atomic.LoadUint64(&state.CurrentSSECount), 10)
+	data += "<br>"
+	data += "CurrentWebSocketCount : " + strconv.FormatUint(atomic.LoadUint64(&state.CurrentWebSocketCount), 10)
+	data += "<br>"
 	data += "TotalErrorCount : " + strconv.FormatUint(state.TotalErrorCount, 10)
 	data += "<br>"
 	state.IntervalRequestData.RLock()
@@ -139,9 +156,21 @@ func (state *ServerStateInfo) ShowHtmlDataRaw(version, globalUniqueId string) st
 	data += "DetailErrorData : " + jsonutil.GetJsonString(state.DetailErrorData.GetCurrentMap())
 	state.DetailErrorData.RUnlock()
 	data += "<br>"
+	state.DetailErrorCodeData.RLock()
+	data += "DetailErrorCodeData : " + jsonutil.GetJsonString(state.DetailErrorCodeData.GetCurrentMap())
+	state.DetailErrorCodeData.RUnlock()
+	data += "<br>"
 	state.DetailHTTPCodeData.RLock()
 	data += "DetailHttpCodeData : " + jsonutil.GetJsonString(state.DetailHTTPCodeData.GetCurrentMap())
 	state.DetailHTTPCodeData.RUnlock()
+	data += "<br>"
+	state.RouteLimitData.RLock()
+	data += "RouteLimitData : " + jsonutil.GetJsonString(state.RouteLimitData.GetCurrentMap())
+	state.RouteLimitData.RUnlock()
+	data += "<br>"
+	state.HubData.RLock()
+	data += "HubData : " + jsonutil.GetJsonString(state.HubData.GetCurrentMap())
+	state.HubData.RUnlock()
 	data += "</div></body></html>"
 	return data
 }
@@ -155,6 +184,8 @@ func (state *ServerStateInfo) ShowHtmlTableData(version, globalUniqueId string)
 	data += "<tr><td>" + "ServerStartTime" + "</td><td>" + state.ServerStartTime.Format(dateTimeLayout) + "</td></tr>"
 	data += "<tr><td>" + "TotalRequestCount" + "</td><td>" + strconv.FormatUint(state.TotalRequestCount, 10) + "</td></tr>"
 	data += "<tr><td>" + "CurrentRequestCount" + "</td><td>" + strconv.FormatUint(state.CurrentRequestCount, 10) + "</td></tr>"
+	data += "<tr><td>" + "CurrentSSECount" + "</td><td>" + strconv.FormatUint(atomic.LoadUint64(&state.CurrentSSECount), 10) + "</td></tr>"
+	data += "<tr><td>" + "CurrentWebSocketCount" + "</td><td>" + strconv.FormatUint(atomic.LoadUint64(&state.CurrentWebSocketCount), 10) + "</td></tr>"
 	data += "<tr><td>" + "TotalErrorCount" + "</td><td>" + strconv.FormatUint(state.TotalErrorCount, 10) + "</td></tr>"
 	state.IntervalErrorData.RLock()
 	data += "<tr><td>" + "IntervalErrorData" + "</td><td>" + jsonutil.GetJsonString(state.IntervalErrorData.GetCurrentMap()) + "</td></tr>"
@@ -165,9 +196,18 @@ func (state *ServerStateInfo) ShowHtmlTableData(version, globalUniqueId string)
 	state.DetailErrorData.RLock()
 	data += "<tr><td>" + "DetailErrorData" + "</td><td>" + jsonutil.GetJsonString(state.DetailErrorData.GetCurrentMap()) + "</td></tr>"
 	state.DetailErrorData.RUnlock()
+	state.DetailErrorCodeData.RLock()
+	data += "<tr><td>" + "DetailErrorCodeData" + "</td><td>" + jsonutil.GetJsonString(state.DetailErrorCodeData.GetCurrentMap()) + "</td></tr>"
+	state.DetailErrorCodeData.RUnlock()
 	state.DetailHTTPCodeData.RLock()
 	data += "<tr><td>" + "DetailHttpCodeData" + "</td><td>" + jsonutil.GetJsonString(state.DetailHTTPCodeData.GetCurrentMap()) + "</td></tr>"
 	state.DetailHTTPCodeData.RUnlock()
+	state.RouteLimitData.RLock()
+	data += "<tr><td>" + "RouteLimitData" + "</td><td>" + jsonutil.GetJsonString(state.RouteLimitData.GetCurrentMap()) + "</td></tr>"
+	state.RouteLimitData.RUnlock()
+	state.HubData.RLock()
+	data += "<tr><td>" + "HubData" + "</td><td>" + jsonutil.GetJsonString(state.HubData.GetCurrentMap()) + "</td></tr>"
+	state.HubData.RUnlock()
 	header := `<tr>
           <th>Index</th>
           <th>Value</th>
@@ -220,14 +260,37 @@ func (state *ServerStateInfo) AddRequestCount(page string, code int, num uint64)
 
 // AddCurrentRequest increment current request count
 func (state *ServerStateInfo) AddCurrentRequest(num uint64) uint64 {
-	atomic.AddUint64(&state.CurrentRequestCount, num)
-	return state.CurrentRequestCount
+	return atomic.AddUint64(&state.CurrentRequestCount, num)
 }
 
 // SubCurrentRequest subtract current request count
 func (state *ServerStateInfo) SubCurrentRequest(num uint64) uint64 {
-	atomic.AddUint64(&state.CurrentRequestCount, ^uint64(num-1))
-	return state.CurrentRequestCount
+	return atomic.AddUint64(&state.CurrentRequestCount, ^uint64(num-1))
+}
+
+// AddSSEStream increment open Server-Sent Events stream count
+func (state *ServerStateInfo) AddSSEStream(num uint64) uint64 {
+	return atomic.AddUint64(&state.CurrentSSECount, num)
+}
+
+// SubSSEStream subtract open Server-Sent Events stream count
+func (state *ServerStateInfo) SubSSEStream(num uint64) uint64 {
+	return atomic.AddUint64(&state.CurrentSSECount, ^uint64(num-1))
+}
+
+// AddWebSocket increment open websocket connection count
+func (state *ServerStateInfo) AddWebSocket(num uint64) uint64 {
+	return atomic.AddUint64(&state.CurrentWebSocketCount, num)
+}
+
+// SubWebSocket subtract open websocket connection count
+func (state *ServerStateInfo) SubWebSocket(num uint64) uint64 {
+	return atomic.AddUint64(&state.CurrentWebSocketCount, ^uint64(num-1))
+}
+
+// SetHubCount set the connection count of websocket hub
+func (state *ServerStateInfo) SetHubCount(hub string, count int) {
+	state.HubData.Set(hub, count)
 }
 
 // AddErrorCount add error count
@@ -237,6 +300,28 @@ func (state *ServerStateInfo) AddErrorCount(page string, err error, num uint64)
 	return state.TotalErrorCount
 }
 
+// AddErrorCodeCount add error count of http status
+func (state *ServerStateInfo) AddErrorCodeCount(code int, num uint64) uint64 {
+	key := strconv.Itoa(code)
+	state.DetailErrorCodeData.Lock()
+	defer state.DetailErrorCodeData.Unlock()
+	val, _ := state.DetailErrorCodeData.innerMap[key].(uint64)
+	val += num
+	state.DetailErrorCodeData.innerMap[key] = val
+	return val
+}
+
+// AddRouteLimitCount add count of requests rejected by route limit
+func (state *ServerStateInfo) AddRouteLimitCount(route, limit string, num uint64) uint64 {
+	key := limit + ":" + route
+	state.RouteLimitData.Lock()
+	defer state.RouteLimitData.Unlock()
+	val, _ := state.RouteLimitData.innerMap[key].(uint64)
+	val += num
+	state.RouteLimitData.innerMap[key] = val
+	return val
+}
+
 func (state *ServerStateInfo) addRequestData(page string, code int, num uint64) {
 	// get from pool
 	info := state.infoPool.requestInfo.Get().(*RequestInfo)
@@ -0,0 +1,241 @@
+package dotweb
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// CorsConfig is the config of CorsMiddleware
+	CorsConfig struct {
+		// AllowOrigins are allowed origins, "*" allow any origin,
+		// and a wildcard can be used in origin, e.g. https://*.example.com
+		AllowOrigins []string
+		// AllowOriginPatterns are regular expressions of allowed origins, e.g. ^https://(a|b)\.example\.com$
+		AllowOriginPatterns []string
+		// AllowMethods are allowed methods of preflight request, default is GET, HEAD, POST, PUT, PATCH, DELETE
+		AllowMethods []string
+		// AllowHeaders are allowed request headers, the request headers of preflight request are allowed if empty
+		AllowHeaders []string
+		// ExposeHeaders are response headers which can be read by client
+		ExposeHeaders []string
+		// AllowCredentials allow cookies and auth headers, it can not be used with "*" origin
+		AllowCredentials bool
+		// MaxAge is the seconds preflight result can be cached, zero means not sent
+		MaxAge int
+	}
+
+	// CorsMiddleware add CORS headers to response, and answer preflight request without calling handler.
+	// Preflight request of route without OPTIONS handler is also answered before 404 and 405,
+	// by the CorsMiddleware of route, group or app in turn
+	CorsMiddleware struct {
+		BaseMiddleware
+		config       CorsConfig
+		allowAll     bool
+		origins      []string
+		wildcards    [][2]string
+		patterns     []*regexp.Regexp
+		allowMethods string
+		allowHeaders string
+		exposeHeader string
+		maxAge       string
+	}
+)
+
+var defaultCorsMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// DefaultCorsConfig return the config which allow any origin with default methods
+func DefaultCorsConfig() CorsConfig {
+	return CorsConfig{AllowOrigins: []string{"*"}}
+}
+
+// NewCorsMiddleware create CorsMiddleware with config, panic if origin pattern is not valid regular expression,
+// or "*" origin is used with AllowCredentials, which let any site read responses with user's credentials
+func NewCorsMiddleware(config CorsConfig) *CorsMiddleware {
+	m := &CorsMiddleware{config: config}
+	for _, origin := range config.AllowOrigins {
+		switch {
+		case origin == "*":
+			m.allowAll = true
+		case strings.Contains(origin, "*"):
+			i := strings.Index(origin, "*")
+			m.wildcards = append(m.wildcards, [2]string{strings.ToLower(origin[:i]), strings.ToLower(origin[i+1:])})
+		default:
+			m.origins = append(m.origins, origin)
+		}
+	}
+	if m.allowAll && config.AllowCredentials {
+		panic("dotweb: CorsConfig AllowOrigins \"*\" can not be used with AllowCredentials, list the trusted origins instead")
+	}
+	for _, pattern := range config.AllowOriginPatterns {
+		m.patterns = append(m.patterns, regexp.MustCompile(pattern))
+	}
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCorsMethods
+	}
+	m.allowMethods = strings.ToUpper(strings.Join(methods, ", "))
+	m.allowHeaders = strings.Join(config.AllowHeaders, ", ")
+	m.exposeHeader = strings.Join(config.ExposeHeaders, ", ")
+	if config.MaxAge > 0 {
+		m.maxAge = strconv.Itoa(config.MaxAge)
+	}
+	return m
+}
+
+// CorsMiddlewareFunc return MiddlewareFunc which create CorsMiddleware with config,
+// it can be registered by DotWeb.RegisterMiddlewareFunc and used by name in config file
+func CorsMiddlewareFunc(config CorsConfig) MiddlewareFunc {
+	return func() Middleware {
+		return NewCorsMiddleware(config)
+	}
+}
+
+// Handle add CORS headers for request with Origin header, preflight request is answered with 204
+func (m *CorsMiddleware) Handle(ctx Context) error {
+	req := ctx.Request().Request
+	origin := req.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return m.Next(ctx)
+	}
+	if isCorsPreflight(req) {
+		allow := ""
+		if node := ctx.RouterNode(); node != nil && node.Node().router != nil {
+			allow = node.Node().router.preflightMethods(req.URL.Path)
+		}
+		return m.preflight(ctx, allow)
+	}
+	h := ctx.Response().Header()
+	addVary(h, HeaderOrigin)
+	if m.AllowOrigin(origin) {
+		m.setAllowOrigin(h, origin)
+		if m.exposeHeader != "" {
+			h.Set(HeaderAccessControlExposeHeaders, m.exposeHeader)
+		}
+	}
+	return m.Next(ctx)
+}
+
+// AllowOrigin check the origin is allowed by config
+func (m *CorsMiddleware) AllowOrigin(origin string) bool {
+	if m.allowAll {
+		return true
+	}
+	for _, o := range m.origins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	lower := strings.ToLower(origin)
+	for _, w := range m.wildcards {
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	for _, p := range m.patterns {
+		if p.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// preflight answer preflight request, 403 is replied if origin is not allowed,
+// allow is the methods computed from route, AllowMethods is used if it is empty
+func (m *CorsMiddleware) preflight(ctx Context, allow string) error {
+	req := ctx.Request().Request
+	h := ctx.Response().Header()
+	addVary(h, HeaderOrigin)
+	addVary(h, HeaderAccessControlRequestMethod)
+	addVary(h, HeaderAccessControlRequestHeaders)
+	origin := req.Header.Get(HeaderOrigin)
+	if !m.AllowOrigin(origin) {
+		return ctx.WriteStringC(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	}
+	m.setAllowOrigin(h, origin)
+	if allow == "" {
+		allow = m.allowMethods
+	}
+	h.Set(HeaderAccessControlAllowMethods, allow)
+	if m.allowHeaders != "" {
+		h.Set(HeaderAccessControlAllowHeaders, m.allowHeaders)
+	} else if reqHeaders := req.Header.Get(HeaderAccessControlRequestHeaders); reqHeaders != "" {
+		h.Set(HeaderAccessControlAllowHeaders, reqHeaders)
+	}
+	if m.maxAge != "" {
+		h.Set(HeaderAccessControlMaxAge, m.maxAge)
+	}
+	return ctx.WriteStringC(http.StatusNoContent, "")
+}
+
+// setAllowOrigin send back the origin, or "*" when any origin is allowed
+func (m *CorsMiddleware) setAllowOrigin(h http.Header, origin string) {
+	if m.allowAll {
+		h.Set(HeaderAccessControlAllowOrigin, "*")
+	} else {
+		h.Set(HeaderAccessControlAllowOrigin, origin)
+	}
+	if m.config.AllowCredentials {
+		h.Set(HeaderAccessControlAllowCredentials, "true")
+	}
+}
+
+// isCorsPreflight check the request is OPTIONS with Origin and Access-Control-Request-Method
+func isCorsPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get(HeaderOrigin) != "" &&
+		req.Header.Get(HeaderAccessControlRequestMethod) != ""
+}
+
+// findCorsMiddleware return the first CorsMiddleware in middleware lists which is not excluded on path
+func findCorsMiddleware(path string, lists ...[]Middleware) *CorsMiddleware {
+	for _, ms := range lists {
+		for _, m := range ms {
+			if cors, ok := m.(*CorsMiddleware); ok && !(m.HasExclude() && m.ExistsExcludeRouter(path)) {
+				return cors
+			}
+		}
+	}
+	return nil
+}
+
+// serveCorsPreflight answer preflight request by the CorsMiddleware of requested route, its group or app,
+// return false if it is not a preflight request or no CorsMiddleware found
+func (r *router) serveCorsPreflight(ctx Context, path string) bool {
+	req := ctx.Request().Request
+	if !isCorsPreflight(req) {
+		return false
+	}
+	var cors *CorsMiddleware
+	reqMethod := strings.ToUpper(req.Header.Get(HeaderAccessControlRequestMethod))
+	if root := r.loadNodes()[reqMethod]; root != nil {
+		if handle, _, node, _ := root.getValue(path); handle != nil {
+			cors = findCorsMiddleware(path, node.middlewares, node.groupMiddlewares)
+		}
+	}
+	if cors == nil {
+		hasCors := func(g *xGroup) bool {
+			return findCorsMiddleware(path, g.middlewares) != nil
+		}
+		if g := r.matchGroup(path, hasCors); g != nil {
+			cors = findCorsMiddleware(path, g.middlewares)
+		}
+	}
+	if cors == nil {
+		cors = findCorsMiddleware(path, r.server.DotApp.Middlewares)
+	}
+	if cors == nil {
+		return false
+	}
+	cors.preflight(ctx, r.preflightMethods(path))
+	return true
+}
+
+// preflightMethods return the allowed methods of path if HttpServer.EnabledAutoCORSPreflight is set
+func (r *router) preflightMethods(path string) string {
+	if !r.server.ServerConfig().EnabledAutoCORSPreflight {
+		return ""
+	}
+	return r.allowed(path, http.MethodOptions)
+}
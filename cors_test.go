@@ -0,0 +1,101 @@
+package dotweb
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestCorsMiddleware_App(t *testing.T) {
+	app := New()
+	app.UseCors(CorsConfig{
+		AllowOrigins:        []string{"https://dotweb.cn", "https://*.example.com"},
+		AllowOriginPatterns: []string{`^https://(a|b)\.devfeel\.com$`},
+		ExposeHeaders:       []string{"X-Total"},
+		AllowCredentials:    true,
+		MaxAge:              600,
+	})
+	app.HttpServer.GET("/api", func(ctx Context) error {
+		return ctx.WriteString("api")
+	})
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/api", http.Header{HeaderOrigin: {"https://api.example.com"}})
+	test.Equal(t, "api", w.Body.String())
+	test.Equal(t, "https://api.example.com", w.Header().Get(HeaderAccessControlAllowOrigin))
+	test.Equal(t, "true", w.Header().Get(HeaderAccessControlAllowCredentials))
+	test.Equal(t, "X-Total", w.Header().Get(HeaderAccessControlExposeHeaders))
+	test.Equal(t, HeaderOrigin, w.Header().Get(HeaderVary))
+
+	w = doTestRequest(app, "GET", "/api", http.Header{HeaderOrigin: {"https://b.devfeel.com"}})
+	test.Equal(t, "https://b.devfeel.com", w.Header().Get(HeaderAccessControlAllowOrigin))
+	w = doTestRequest(app, "GET", "/api", http.Header{HeaderOrigin: {"https://evil.com"}})
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "", w.Header().Get(HeaderAccessControlAllowOrigin))
+
+	preflight := http.Header{
+		HeaderOrigin:                      {"https://dotweb.cn"},
+		HeaderAccessControlRequestMethod:  {"POST"},
+		HeaderAccessControlRequestHeaders: {"X-Token"},
+	}
+	// answered before 404
+	w = doTestRequest(app, "OPTIONS", "/missing", preflight)
+	test.Equal(t, http.StatusNoContent, w.Code)
+	test.Equal(t, "https://dotweb.cn", w.Header().Get(HeaderAccessControlAllowOrigin))
+	test.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", w.Header().Get(HeaderAccessControlAllowMethods))
+	test.Equal(t, "X-Token", w.Header().Get(HeaderAccessControlAllowHeaders))
+	test.Equal(t, "600", w.Header().Get(HeaderAccessControlMaxAge))
+
+	preflight.Set(HeaderOrigin, "https://evil.com")
+	w = doTestRequest(app, "OPTIONS", "/api", preflight)
+	test.Equal(t, http.StatusForbidden, w.Code)
+	test.Equal(t, "", w.Header().Get(HeaderAccessControlAllowOrigin))
+}
+
+func TestCorsMiddleware_AnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		test.NotNil(t, recover())
+	}()
+	NewCorsMiddleware(CorsConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestCorsMiddleware_GroupAndRoute(t *testing.T) {
+	app := New()
+	app.HttpServer.SetEnabledAutoOPTIONS(true)
+	app.RegisterMiddlewareFunc("cors", CorsMiddlewareFunc(CorsConfig{AllowOrigins: []string{"*"}, AllowMethods: []string{"GET", "POST"}}))
+	corsFunc, exists := app.GetMiddlewareFunc("cors")
+	test.Equal(t, true, exists)
+	handler := func(ctx Context) error {
+		return ctx.WriteString("ok")
+	}
+	app.HttpServer.Group("/g").Use(corsFunc()).GET("/a", handler)
+	app.HttpServer.GET("/r", handler).Use(NewCorsMiddleware(CorsConfig{AllowOrigins: []string{"https://dotweb.cn"}, AllowHeaders: []string{"X-Token"}}))
+	app.HttpServer.POST("/plain", handler)
+	prepareTestApp(app)
+
+	preflight := http.Header{
+		HeaderOrigin:                     {"https://dotweb.cn"},
+		HeaderAccessControlRequestMethod: {"GET"},
+	}
+	w := doTestRequest(app, "OPTIONS", "/g/a", preflight)
+	test.Equal(t, http.StatusNoContent, w.Code)
+	test.Equal(t, "*", w.Header().Get(HeaderAccessControlAllowOrigin))
+	test.Equal(t, "GET, POST", w.Header().Get(HeaderAccessControlAllowMethods))
+	// group cors answer preflight of path without route
+	w = doTestRequest(app, "OPTIONS", "/g/missing", preflight)
+	test.Equal(t, http.StatusNoContent, w.Code)
+
+	// route cors is used even if OPTIONS route is auto added
+	w = doTestRequest(app, "OPTIONS", "/r", preflight)
+	test.Equal(t, http.StatusNoContent, w.Code)
+	test.Equal(t, "https://dotweb.cn", w.Header().Get(HeaderAccessControlAllowOrigin))
+	test.Equal(t, "X-Token", w.Header().Get(HeaderAccessControlAllowHeaders))
+
+	// no cors middleware, auto options is used
+	w = doTestRequest(app, "OPTIONS", "/plain", preflight)
+	test.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", w.Header().Get(HeaderAccessControlAllowMethods))
+	w = doTestRequest(app, "GET", "/plain", preflight)
+	test.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	test.Equal(t, "", w.Header().Get(HeaderAccessControlAllowOrigin))
+}
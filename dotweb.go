@@ -6,7 +6,6 @@ import (
 	"github.com/devfeel/dotweb/framework/exception"
 	"net/http"
 	_ "net/http/pprof"
-	"runtime/debug"
 	"strconv"
 	"strings"
 
@@ -46,6 +45,8 @@ type (
 		middlewareMutex         *sync.RWMutex
 		pluginMap               map[string]Plugin
 		pluginMutex             *sync.RWMutex
+		hubMap                  map[string]*Hub
+		hubMutex                *sync.RWMutex
 		StartMode               string
 		IDGenerater             IdGenerate
 		globalUniqueID          string
@@ -103,6 +104,8 @@ func New() *DotWeb {
 		middlewareMutex: new(sync.RWMutex),
 		pluginMap:       make(map[string]Plugin),
 		pluginMutex:     new(sync.RWMutex),
+		hubMap:          make(map[string]*Hub),
+		hubMutex:        new(sync.RWMutex),
 		StartMode:       StartMode_New,
 		serverStateInfo: core.NewServerStateInfo(),
 	}
@@ -273,6 +276,11 @@ func (app *DotWeb) UseTimeoutHook(handler StandardHandle, timeout time.Duration)
 	})
 }
 
+// UseCors register CorsMiddleware with config
+func (app *DotWeb) UseCors(config CorsConfig) {
+	app.Use(NewCorsMiddleware(config))
+}
+
 // SetMock set mock logic
 func (app *DotWeb) SetMock(mock Mock) {
 	app.Mock = mock
@@ -406,6 +414,9 @@ func (app *DotWeb) ListenAndServe(addr string) error {
 	// output run mode
 	app.Logger().Debug("DotWeb RunMode is "+app.RunMode(), LogTarget_HttpServer)
 
+	// routes registered from now on take effect immediately
+	app.HttpServer.serving.Store(true)
+
 	// start plugins
 	app.initPlugins()
 
@@ -533,32 +544,10 @@ func (app *DotWeb) initPlugins() {
 
 // init bind app's middleware to router node
 func (app *DotWeb) initBindMiddleware() {
-	router := app.HttpServer.Router().(*router)
-	// bind app middlewares
-	for fullExpress, _ := range router.allRouterExpress {
-		expresses := strings.Split(fullExpress, routerExpressSplit)
-		if len(expresses) < 2 {
-			continue
-		}
-		node := router.getNode(expresses[0], expresses[1])
-		if node == nil {
-			continue
-		}
-
-		node.appMiddlewares = app.Middlewares
-		for _, m := range node.appMiddlewares {
-			if m.HasExclude() && m.ExistsExcludeRouter(node.fullPath) {
-				app.Logger().Debug("DotWeb initBindMiddleware [app] "+fullExpress+" "+reflect.TypeOf(m).String()+" exclude", LogTarget_HttpServer)
-				node.hasExcludeMiddleware = true
-			} else {
-				app.Logger().Debug("DotWeb initBindMiddleware [app] "+fullExpress+" "+reflect.TypeOf(m).String()+" match", LogTarget_HttpServer)
-			}
-		}
-		if len(node.middlewares) > 0 {
-			firstMiddleware := &xMiddleware{}
-			firstMiddleware.SetNext(node.middlewares[0])
-			node.middlewares = append([]Middleware{firstMiddleware}, node.middlewares...)
-		}
+	// bind app middlewares on main router and host routers
+	app.bindAppMiddleware(app.HttpServer.Router().(*router))
+	for _, h := range app.HttpServer.hosts {
+		app.bindAppMiddleware(h.router)
 	}
 
 	// bind group middlewares
@@ -571,7 +560,10 @@ func (app *DotWeb) initBindMiddleware() {
 			if len(expresses) < 2 {
 				continue
 			}
-			node := router.getNode(expresses[0], expresses[1])
+			node := g.router.getNode(expresses[0], expresses[1])
+			if g.version != "" {
+				node = node.variantOf(g)
+			}
 			if node == nil {
 				continue
 			}
@@ -588,6 +580,42 @@ func (app *DotWeb) initBindMiddleware() {
 	}
 }
 
+// bindAppMiddleware bind app middlewares to all nodes of router
+func (app *DotWeb) bindAppMiddleware(router *router) {
+	for fullExpress := range router.GetAllRouterExpress() {
+		expresses := strings.Split(fullExpress, routerExpressSplit)
+		if len(expresses) < 2 {
+			continue
+		}
+		node := router.getNode(expresses[0], expresses[1])
+		if node == nil {
+			continue
+		}
+		app.bindNodeAppMiddleware(fullExpress, node)
+		for _, v := range node.variants {
+			app.bindNodeAppMiddleware(fullExpress, v)
+		}
+	}
+}
+
+// bindNodeAppMiddleware bind app middlewares to the route node
+func (app *DotWeb) bindNodeAppMiddleware(fullExpress string, node *Node) {
+	node.appMiddlewares = app.Middlewares
+	for _, m := range node.appMiddlewares {
+		if m.HasExclude() && m.ExistsExcludeRouter(node.fullPath) {
+			app.Logger().Debug("DotWeb initBindMiddleware [app] "+fullExpress+" "+reflect.TypeOf(m).String()+" exclude", LogTarget_HttpServer)
+			node.hasExcludeMiddleware = true
+		} else {
+			app.Logger().Debug("DotWeb initBindMiddleware [app] "+fullExpress+" "+reflect.TypeOf(m).String()+" match", LogTarget_HttpServer)
+		}
+	}
+	if len(node.middlewares) > 0 {
+		firstMiddleware := &xMiddleware{}
+		firstMiddleware.SetNext(node.middlewares[0])
+		node.middlewares = append([]Middleware{firstMiddleware}, node.middlewares...)
+	}
+}
+
 // IncludeDotwebGroup init inner routers which start with /dotweb/
 func (app *DotWeb) IncludeDotwebGroup() {
 	initDotwebGroup(app.HttpServer)
@@ -653,15 +681,14 @@ func (app *DotWeb) initServerEnvironment() {
 }
 
 // DefaultHTTPErrorHandler default exception handler
+// HTTPError is replied with its code, other errors are mapped by toHTTPError,
+// the body is plain text, problem+json or html by request Accept
 func (app *DotWeb) DefaultHTTPErrorHandler(ctx Context, err error) {
-	ctx.Response().Header().Set(HeaderContentType, CharsetUTF8)
-	// if in development mode, output the error info
-	if app.IsDevelopmentMode() {
-		stack := string(debug.Stack())
-		ctx.WriteStringC(http.StatusInternalServerError, fmt.Sprintln(err)+stack)
-	} else {
-		ctx.WriteStringC(http.StatusInternalServerError, "Internal Server Error")
+	// websocket is closed with CloseInternalServerErr, nothing can be written
+	if ctx.IsWebSocket() {
+		return
 	}
+	app.writeHTTPError(ctx, toHTTPError(err))
 }
 
 func (app *DotWeb) printDotLogo() {
@@ -679,9 +706,11 @@ func (app *DotWeb) Close() error {
 	return app.HttpServer.stdServer.Close()
 }
 
-// Shutdown stops server gracefully.
+// Shutdown stops server gracefully, connections of websocket hubs are closed with CloseGoingAway.
 // It internally calls `http.Server#Shutdown()`.
 func (app *DotWeb) Shutdown(ctx context.Context) error {
+	// hijacked websocket connections are not closed by http server
+	app.closeHubs(ctx)
 	return app.HttpServer.stdServer.Shutdown(ctx)
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/devfeel/dotweb/core"
 	jsonutil "github.com/devfeel/dotweb/framework/json"
+	"html"
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
@@ -101,23 +102,55 @@ func showQuery(ctx Context) error {
 	}
 }
 
+// show all routes of server, use query key "json" to get json data
 func showRouters(ctx Context) error {
+	routes := ctx.HttpServer().Router().Routes()
+	for _, h := range ctx.HttpServer().hosts {
+		routes = append(routes, h.router.Routes()...)
+	}
+	if ctx.Request().ExistsQueryKey("json") {
+		return ctx.WriteJson(routes)
+	}
+
 	data := ""
-	routerCount := len(ctx.HttpServer().router.GetAllRouterExpress())
-	for k, _ := range ctx.HttpServer().router.GetAllRouterExpress() {
-		method := strings.Split(k, routerExpressSplit)[0]
-		router := strings.Split(k, routerExpressSplit)[1]
-		data += "<tr><td>" + method + "</td><td>" + router + "</td></tr>"
+	for _, route := range routes {
+		var flags []string
+		if route.IsStatic {
+			flags = append(flags, "static")
+		}
+		if route.IsHijack {
+			flags = append(flags, "hijack")
+		}
+		if route.IsWebSocket {
+			flags = append(flags, "websocket")
+		}
+		if route.Version != "" {
+			flags = append(flags, "version:"+html.EscapeString(route.Version))
+		}
+		middlewares := append(append(append([]string{}, route.AppMiddlewares...), route.GroupMiddlewares...), route.Middlewares...)
+		data += "<tr><td>" + route.Method + "</td><td>" + html.EscapeString(route.Host+route.Path) + "</td><td>" + html.EscapeString(route.Name) +
+			"</td><td>" + html.EscapeString(route.Group) + "</td><td>" + html.EscapeString(route.Handler) +
+			"</td><td>" + html.EscapeString(strings.Join(middlewares, " > ")) + "</td><td>" + strings.Join(flags, ",") + "</td></tr>"
 	}
 	col := `<colgroup>
-		  <col width="40%">
-		  <col width="60%">
+		  <col width="8%">
+		  <col width="22%">
+		  <col width="10%">
+		  <col width="10%">
+		  <col width="20%">
+		  <col width="22%">
+		  <col width="8%">
 		</colgroup>`
 	header := `<tr>
           <th>Method</th>
           <th>Router</th>
+          <th>Name</th>
+          <th>Group</th>
+          <th>Handler</th>
+          <th>Middlewares</th>
+          <th>Flags</th>
         </tr>`
-	html := core.CreateTableHtml(col, "Routers:"+fmt.Sprint(routerCount), header, data)
+	tableHtml := core.CreateTableHtml(col, "Routers:"+fmt.Sprint(len(routes)), header, data)
 
-	return ctx.WriteHtml(html)
+	return ctx.WriteHtml(tableHtml)
 }
@@ -146,6 +146,11 @@ func (b *userBinder) BindJsonBody(i interface{}, ctx dotweb.Context) (err error)
 	return err
 }
 
+//RegisterDecoder userBinder only support json and xml, ignore the registered decoder
+func (b *userBinder) RegisterDecoder(mime string, decoder dotweb.Decoder) {
+	fmt.Println("UserBind.RegisterDecoder", mime)
+}
+
 func newUserBinder() *userBinder {
 	return &userBinder{}
 }
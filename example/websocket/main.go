@@ -8,86 +8,77 @@ import (
 	"log"
 
 	"github.com/devfeel/dotweb"
-	"golang.org/x/net/websocket"
 )
 
 // Connected clients
-var clients = make(map[*websocket.Conn]bool)
+var clients = make(map[*dotweb.WebSocket]bool)
 var broadcast = make(chan string)
 
 func main() {
 	// Create DotWeb app
 	app := dotweb.New()
 	app.SetDevelopmentMode()
-	
+
 	// WebSocket endpoint - echo server
-	app.HttpServer.GET("/ws", func(ctx dotweb.Context) error {
-		// Check if WebSocket request
-		if !ctx.IsWebSocket() {
-			return ctx.WriteString("This endpoint requires WebSocket connection")
-		}
-		
+	// the handler is called after handshake, middlewares run before it
+	app.HttpServer.WebSocket("/ws", func(ctx dotweb.Context) error {
 		// Get WebSocket connection
 		ws := ctx.WebSocket()
-		
+
 		// Register client
-		clients[ws.Conn] = true
+		clients[ws] = true
 		log.Printf("Client connected. Total: %d", len(clients))
-		
+
 		// Send welcome message
 		ws.SendMessage("Welcome to DotWeb WebSocket!")
-		
+
 		// Read messages in loop
 		for {
 			msg, err := ws.ReadMessage()
 			if err != nil {
 				log.Printf("Client disconnected: %v", err)
-				delete(clients, ws.Conn)
+				delete(clients, ws)
 				break
 			}
-			
+
 			log.Printf("Received: %s", msg)
-			
+
 			// Echo back
 			ws.SendMessage("Echo: " + msg)
 		}
-		
+
 		return nil
 	})
-	
+
 	// WebSocket chat endpoint
-	app.HttpServer.GET("/chat", func(ctx dotweb.Context) error {
-		if !ctx.IsWebSocket() {
-			return ctx.WriteString("This endpoint requires WebSocket connection")
-		}
-		
+	app.HttpServer.WebSocket("/chat", func(ctx dotweb.Context) error {
 		ws := ctx.WebSocket()
-		clients[ws.Conn] = true
-		
+		clients[ws] = true
+
 		// Get username from query
 		username := ctx.Request().QueryString("name")
 		if username == "" {
 			username = "Anonymous"
 		}
-		
+
 		// Announce join
 		broadcast <- fmt.Sprintf("🔔 %s joined the chat", username)
-		
+
 		// Read messages
 		for {
 			msg, err := ws.ReadMessage()
 			if err != nil {
-				delete(clients, ws.Conn)
+				delete(clients, ws)
 				broadcast <- fmt.Sprintf("🚪 %s left the chat", username)
 				break
 			}
-			
+
 			broadcast <- fmt.Sprintf("💬 %s: %s", username, msg)
 		}
-		
+
 		return nil
 	})
-	
+
 	// HTTP endpoint to check WebSocket status
 	app.HttpServer.GET("/status", func(ctx dotweb.Context) error {
 		return ctx.WriteString(fmt.Sprintf(
@@ -99,10 +90,10 @@ func main() {
 			len(clients),
 		))
 	})
-	
+
 	// Start broadcast goroutine
 	go handleBroadcast()
-	
+
 	fmt.Println("🚀 WebSocket example running at http://localhost:8080")
 	fmt.Println("\nWebSocket endpoints:")
 	fmt.Println("  ws://localhost:8080/ws          - Echo server")
@@ -112,7 +103,7 @@ func main() {
 	fmt.Println("\nTest with wscat:")
 	fmt.Println("  wscat -c ws://localhost:8080/ws")
 	fmt.Println("  wscat -c 'ws://localhost:8080/chat?name=Alice'")
-	
+
 	if err := app.StartServer(8080); err != nil {
 		log.Fatal(err)
 	}
@@ -122,10 +113,9 @@ func main() {
 func handleBroadcast() {
 	for msg := range broadcast {
 		for conn := range clients {
-			// Use websocket.Message.Send directly
-			err := websocket.Message.Send(conn, msg)
+			err := conn.SendMessage(msg)
 			if err != nil {
-				conn.Close()
+				conn.Close(dotweb.CloseGoingAway, "")
 				delete(clients, conn)
 			}
 		}
@@ -139,6 +139,22 @@ func (rc *RedisClient) INCR(key string) (int, error) {
 	return int(val), err
 }
 
+// incrWithExpireScript increment the counter and set its expire when it is created, in one atomic call
+var incrWithExpireScript = redis.NewScript(`
+local val = redis.call("INCR", KEYS[1])
+if val == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return val
+`)
+
+// IncrWithExpire atomically increment the value by 1 specified by key,
+// the expire duration is set when the key is created, so the counter never lives without it
+func (rc *RedisClient) IncrWithExpire(key string, timeOutSeconds int64) (int64, error) {
+	ctx := context.Background()
+	return incrWithExpireScript.Run(ctx, rc.client, []string{key}, timeOutSeconds).Int64()
+}
+
 // DECR atomically decrement the value by 1 specified by key
 func (rc *RedisClient) DECR(key string) (int, error) {
 	ctx := context.Background()
@@ -2,6 +2,7 @@ package redisutil
 
 import (
 	"testing"
+	"time"
 )
 
 // redisAvailable indicates if Redis server is available for testing
@@ -150,6 +151,28 @@ func TestRedisClient_INCR(t *testing.T) {
 	client.Del(key)
 }
 
+// TestRedisClient_IncrWithExpire tests IncrWithExpire operation
+func TestRedisClient_IncrWithExpire(t *testing.T) {
+	skipIfNoRedis(t)
+	client := GetDefaultRedisClient("redis://localhost:6379/0")
+	key := "test_incr_expire_key"
+	client.Del(key)
+	for want := int64(1); want <= 2; want++ {
+		val, err := client.IncrWithExpire(key, 1)
+		if err != nil {
+			t.Errorf("IncrWithExpire failed: %v", err)
+		}
+		if val != want {
+			t.Errorf("IncrWithExpire returned wrong value: got %d, want %d", val, want)
+		}
+	}
+	time.Sleep(1100 * time.Millisecond)
+	exists, _ := client.Exists(key)
+	if exists {
+		t.Error("IncrWithExpire key should be expired")
+	}
+}
+
 // TestRedisClient_DECR tests DECR operation
 func TestRedisClient_DECR(t *testing.T) {
 	skipIfNoRedis(t)
@@ -1,58 +1,240 @@
 package reflects
 
 import (
+	"encoding"
 	"errors"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	defaultValueTagName = "default"
+	timeLayoutTagName   = "layout"
+	// maxNestedDepth stop converting too deep nested struct
+	maxNestedDepth = 32
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// mapConverter set struct fields with values in form
+type mapConverter struct {
+	tagName string
+	form    map[string][]string
+	// tagOnly only set fields with tagName tag
+	tagOnly bool
+	keyFunc func(string) string
+	depth   int
+}
+
 // convert map to struct
+// field is matched by tagName tag, or field name if no tag.
+// Nested struct field can be set by "addr.city" or "addr[city]" notation, untagged nested struct also read the flat keys.
+// Supported field types: numbers, bool, string, slices, []byte, time.Time (use `layout` tag, default is RFC3339, "unix" for unix seconds),
+// time.Duration, pointers and types implement encoding.TextUnmarshaler.
+// `default` tag value is used when no value in form and field is zero, for slices it is split by ","
 func ConvertMapToStruct(tagName string, ptr interface{}, form map[string][]string) error {
-	typ := reflect.TypeOf(ptr).Elem()
-	val := reflect.ValueOf(ptr).Elem()
+	c := &mapConverter{tagName: tagName, form: normalizeFormKeys(form)}
+	_, err := c.convert(reflect.ValueOf(ptr).Elem(), "")
+	return err
+}
+
+// ConvertTaggedMapToStruct convert map to struct like ConvertMapToStruct, but only fields with tagName tag are set,
+// fields without tag which are struct are converted recursively.
+// keyFunc is used to normalize tag value before lookup in form, e.g. http.CanonicalHeaderKey, can be nil.
+// ptr which is not a pointer to struct is ignored
+func ConvertTaggedMapToStruct(tagName string, ptr interface{}, form map[string][]string, keyFunc func(string) string) error {
+	val := reflect.ValueOf(ptr)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	c := &mapConverter{tagName: tagName, form: normalizeFormKeys(form), tagOnly: true, keyFunc: keyFunc}
+	_, err := c.convert(val.Elem(), "")
+	return err
+}
 
+// convert set fields of struct val, return whether any field is set
+func (c *mapConverter) convert(val reflect.Value, prefix string) (bool, error) {
+	typ := val.Type()
+	isSet := false
 	for i := 0; i < typ.NumField(); i++ {
 		typeField := typ.Field(i)
 		structField := val.Field(i)
 		if !structField.CanSet() {
 			continue
 		}
-		structFieldKind := structField.Kind()
-		inputFieldName := typeField.Tag.Get(tagName)
-
-		if inputFieldName == "" {
+		inputFieldName := typeField.Tag.Get(c.tagName)
+		if inputFieldName == "-" {
+			continue
+		}
+		tagged := inputFieldName != ""
+		if !tagged {
 			inputFieldName = typeField.Name
-			// If "form" tag is nil, we inspect if the field is a struct.
-			if structFieldKind == reflect.Struct {
-				err := ConvertMapToStruct(tagName, structField.Addr().Interface(), form)
-				if err != nil {
-					return err
-				}
-				continue
+		}
+		if c.keyFunc != nil {
+			inputFieldName = c.keyFunc(inputFieldName)
+		}
+		key := inputFieldName
+		if prefix != "" {
+			key = prefix + "." + inputFieldName
+		}
+
+		if isNestedStruct(structField.Type()) {
+			set, err := c.convertNested(structField, key, prefix, tagged)
+			if err != nil {
+				return isSet, err
 			}
+			isSet = isSet || set
+			continue
 		}
-		inputValue, exists := form[inputFieldName]
-		if !exists {
+		if c.tagOnly && !tagged {
 			continue
 		}
 
-		numElems := len(inputValue)
-		if structFieldKind == reflect.Slice && numElems > 0 {
-			sliceOf := structField.Type().Elem().Kind()
-			slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
-			for i := 0; i < numElems; i++ {
-				if err := setWithProperType(sliceOf, inputValue[i], slice.Index(i)); err != nil {
-					return err
-				}
+		inputValue, exists := c.form[key]
+		if !exists || len(inputValue) == 0 {
+			defaultValue, hasDefault := typeField.Tag.Lookup(defaultValueTagName)
+			if !hasDefault || !structField.IsZero() {
+				continue
+			}
+			inputValue = []string{defaultValue}
+			if structField.Kind() == reflect.Slice && structField.Type().Elem().Kind() != reflect.Uint8 {
+				inputValue = strings.Split(defaultValue, ",")
 			}
-			val.Field(i).Set(slice)
-		} else {
-			if err := setWithProperType(typeField.Type.Kind(), inputValue[0], structField); err != nil {
+		}
+		if err := setFieldValue(structField, typeField, inputValue); err != nil {
+			return isSet, err
+		}
+		isSet = true
+	}
+	return isSet, nil
+}
+
+// convertNested set nested struct field, pointer is allocated only when any field is set.
+// untagged struct read the flat keys like before, and also the keys with field name prefix,
+// pointer field is only converted when form has keys with its prefix, so self-referencing types terminate
+func (c *mapConverter) convertNested(field reflect.Value, key, prefix string, tagged bool) (bool, error) {
+	hasPrefix := c.hasKeyPrefix(key + ".")
+	if field.Kind() == reflect.Ptr && !hasPrefix {
+		return false, nil
+	}
+	if c.depth >= maxNestedDepth {
+		return false, errors.New("nested struct is too deep")
+	}
+	c.depth++
+	defer func() { c.depth-- }()
+	target := field
+	if field.Kind() == reflect.Ptr {
+		target = reflect.New(field.Type().Elem()).Elem()
+		if !field.IsNil() {
+			target.Set(field.Elem())
+		}
+	}
+	isSet := false
+	if !tagged && field.Kind() != reflect.Ptr {
+		set, err := c.convert(target, prefix)
+		if err != nil {
+			return false, err
+		}
+		isSet = set
+	}
+	if tagged || hasPrefix {
+		set, err := c.convert(target, key)
+		if err != nil {
+			return false, err
+		}
+		isSet = isSet || set
+	}
+	if isSet && field.Kind() == reflect.Ptr {
+		field.Set(target.Addr())
+	}
+	return isSet, nil
+}
+
+func (c *mapConverter) hasKeyPrefix(prefix string) bool {
+	for k := range c.form {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNestedStruct check type is struct or pointer to struct which is not set by text
+func isNestedStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && typ != timeType && !reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+// normalizeFormKeys convert "addr[city]" notation to "addr.city", "tags[]" to "tags"
+func normalizeFormKeys(form map[string][]string) map[string][]string {
+	hasBracket := false
+	for k := range form {
+		if strings.Contains(k, "[") {
+			hasBracket = true
+			break
+		}
+	}
+	if !hasBracket {
+		return form
+	}
+	normalized := make(map[string][]string, len(form))
+	for k, v := range form {
+		if strings.Contains(k, "[") {
+			k = strings.NewReplacer("[]", "", "[", ".", "]", "").Replace(k)
+		}
+		normalized[k] = append(normalized[k], v...)
+	}
+	return normalized
+}
+
+// setFieldValue set field with values, slices use all values, others use the first one
+func setFieldValue(field reflect.Value, typeField reflect.StructField, values []string) error {
+	switch {
+	case field.Kind() != reflect.Slice || field.Addr().Type().Implements(textUnmarshalerType):
+		return setSingleValue(field, typeField, values[0])
+	case field.Type().Elem().Kind() == reflect.Uint8:
+		field.SetBytes([]byte(values[0]))
+		return nil
+	default:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setSingleValue(slice.Index(i), typeField, value); err != nil {
 				return err
 			}
 		}
+		field.Set(slice)
+		return nil
 	}
-	return nil
+}
+
+// setSingleValue set field with one value
+func setSingleValue(field reflect.Value, typeField reflect.StructField, value string) error {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setSingleValue(elem.Elem(), typeField, value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	switch field.Type() {
+	case timeType:
+		return setTimeField(value, typeField.Tag.Get(timeLayoutTagName), field)
+	case durationType:
+		return setDurationField(value, field)
+	}
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	return setWithProperType(field.Kind(), value, field)
 }
 
 func setWithProperType(valueKind reflect.Kind, val string, structField reflect.Value) error {
@@ -134,3 +316,39 @@ func setFloatField(value string, bitSize int, field reflect.Value) error {
 	}
 	return err
 }
+
+func setTimeField(value string, layout string, field reflect.Value) error {
+	if value == "" {
+		return nil
+	}
+	var timeVal time.Time
+	switch layout {
+	case "unix":
+		sec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		timeVal = time.Unix(sec, 0)
+	case "":
+		layout = time.RFC3339
+		fallthrough
+	default:
+		var err error
+		if timeVal, err = time.Parse(layout, value); err != nil {
+			return err
+		}
+	}
+	field.Set(reflect.ValueOf(timeVal))
+	return nil
+}
+
+func setDurationField(value string, field reflect.Value) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil {
+		field.SetInt(int64(d))
+	}
+	return err
+}
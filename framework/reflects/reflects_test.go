@@ -1,7 +1,12 @@
 package reflects
 
 import (
+	"net"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
 )
 
 // 以下是功能测试
@@ -12,6 +17,86 @@ func Test_ConvertMapToStruct_1(t *testing.T) {
 	t.Log("ok")
 }
 
+type testAddress struct {
+	City string `form:"city"`
+	Zip  *int   `form:"zip"`
+}
+
+type testRichForm struct {
+	Name     *string       `form:"name"`
+	Scores   []int         `form:"score"`
+	Raw      []byte        `form:"raw"`
+	Birthday time.Time     `form:"birthday" layout:"2006-01-02"`
+	Created  time.Time     `form:"created" layout:"unix"`
+	Timeout  time.Duration `form:"timeout"`
+	IP       net.IP        `form:"ip"`
+	Page     int           `form:"page" default:"1"`
+	Tags     []string      `form:"tags" default:"a,b"`
+	Home     testAddress   `form:"home"`
+	Work     *testAddress  `form:"work"`
+	None     *testAddress  `form:"none"`
+	Office   testAddress
+}
+
+func Test_ConvertMapToStruct_RichTypes(t *testing.T) {
+	form := map[string][]string{
+		"name":       {"dotweb"},
+		"score":      {"1", "2"},
+		"raw":        {"bytes"},
+		"birthday":   {"2020-01-02"},
+		"created":    {"1600000000"},
+		"timeout":    {"1m30s"},
+		"ip":         {"127.0.0.1"},
+		"home.city":  {"shanghai"},
+		"work[city]": {"beijing"},
+		"work[zip]":  {"100000"},
+		"city":       {"flat"},
+	}
+	v := &testRichForm{}
+	test.Nil(t, ConvertMapToStruct("form", v, form))
+	test.Equal(t, "dotweb", *v.Name)
+	test.Equal(t, []int{1, 2}, v.Scores)
+	test.Equal(t, "bytes", string(v.Raw))
+	test.Equal(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), v.Birthday)
+	test.Equal(t, int64(1600000000), v.Created.Unix())
+	test.Equal(t, 90*time.Second, v.Timeout)
+	test.Equal(t, "127.0.0.1", v.IP.String())
+	test.Equal(t, 1, v.Page)
+	test.Equal(t, []string{"a", "b"}, v.Tags)
+	test.Equal(t, "shanghai", v.Home.City)
+	test.Equal(t, "beijing", v.Work.City)
+	test.Equal(t, 100000, *v.Work.Zip)
+	test.Nil(t, v.None)
+	// untagged struct read flat keys
+	test.Equal(t, "flat", v.Office.City)
+
+	test.NotNil(t, ConvertMapToStruct("form", &testRichForm{}, map[string][]string{"birthday": {"bad"}}))
+}
+
+type testCategory struct {
+	Name   string
+	Parent *testCategory
+}
+
+func Test_ConvertMapToStruct_Recursive(t *testing.T) {
+	v := &testCategory{}
+	test.Nil(t, ConvertMapToStruct("form", v, map[string][]string{"Name": {"phone"}}))
+	test.Equal(t, "phone", v.Name)
+	test.Nil(t, v.Parent)
+
+	v = &testCategory{}
+	form := map[string][]string{"Name": {"phone"}, "Parent.Name": {"digital"}, "Parent.Parent.Name": {"all"}}
+	test.Nil(t, ConvertMapToStruct("form", v, form))
+	test.Equal(t, "digital", v.Parent.Name)
+	test.Equal(t, "all", v.Parent.Parent.Name)
+	test.Nil(t, v.Parent.Parent.Parent)
+
+	test.Nil(t, ConvertTaggedMapToStruct("query", &testCategory{}, map[string][]string{"Name": {"phone"}}, nil))
+
+	deep := strings.Repeat("Parent.", maxNestedDepth+1) + "Name"
+	test.NotNil(t, ConvertMapToStruct("form", &testCategory{}, map[string][]string{deep: {"x"}}))
+}
+
 // setWithProperType(valueKind reflect.Kind, val string, structField reflect.Value) error
 
 // setIntField(value string, bitSize int, field reflect.Value) error
@@ -4,7 +4,6 @@ go 1.24
 
 require (
 	github.com/redis/go-redis/v9 v9.18.0
-	golang.org/x/net v0.33.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
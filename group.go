@@ -1,6 +1,9 @@
 package dotweb
 
-import "reflect"
+import (
+	"net/http"
+	"reflect"
+)
 
 // Group is the interface that wraps the group router methods.
 // A Group allows you to create routes with a common prefix and middleware chain.
@@ -27,8 +30,18 @@ type Group interface {
 	ServerFile(path string, fileroot string) RouterNode
 	// RegisterRoute registers a new route with the given HTTP method, path and handler.
 	RegisterRoute(method, path string, h HttpHandle) RouterNode
+	// Version creates a group with the same prefix whose routes are variants picked when matcher returns version.
+	Version(version string, matcher VersionMatcher) Group
+	// SetDefaultVersion sets the version used when request version matches no variant.
+	SetDefaultVersion(version string) Group
+	// Mount forwards all requests below prefix to a standard http.Handler with the full prefix stripped.
+	Mount(prefix string, handler http.Handler) Group
 	// SetNotFoundHandle sets a custom 404 handler for this group.
 	SetNotFoundHandle(handler StandardHandle) Group
+	// SetMethodNotAllowedHandle sets a custom 405 handler for this group.
+	SetMethodNotAllowedHandle(handler StandardHandle) Group
+	// SetOptionsHandle sets a custom handler for automatic OPTIONS reply of this group.
+	SetOptionsHandle(handler StandardHandle) Group
 }
 
 // xGroup is the implementation of Group interface.
@@ -37,11 +50,25 @@ type xGroup struct {
 	middlewares      []Middleware
 	allRouterExpress map[string]struct{}
 	server           *HttpServer
+	router           *router
 	notFoundHandler  StandardHandle
+	// methodNotAllowedHandler and optionsHandler are picked by the same prefix rule as notFoundHandler
+	methodNotAllowedHandler StandardHandle
+	optionsHandler          StandardHandle
+	// version group fields, versionParent is the group which Version is called on
+	version        string
+	versionMatcher VersionMatcher
+	versionParent  *xGroup
+	defaultVersion string
 }
 
 func NewGroup(prefix string, server *HttpServer) Group {
-	g := &xGroup{prefix: prefix, server: server, allRouterExpress: make(map[string]struct{})}
+	return newGroup(prefix, server, server.router.(*router))
+}
+
+// newGroup create group which register routes on the given router
+func newGroup(prefix string, server *HttpServer, r *router) *xGroup {
+	g := &xGroup{prefix: prefix, server: server, router: r, allRouterExpress: make(map[string]struct{})}
 	server.groups = append(server.groups, g)
 	server.Logger().Debug("DotWeb:Group NewGroup ["+prefix+"]", LogTarget_HttpServer)
 	return g
@@ -115,14 +142,49 @@ func (g *xGroup) PUT(path string, h HttpHandle) RouterNode {
 // PUT implements `Router#PUT()` for sub-routes within the Group.
 func (g *xGroup) ServerFile(path string, fileroot string) RouterNode {
 	g.allRouterExpress[RouteMethod_GET+routerExpressSplit+g.prefix+path] = struct{}{}
-	node := g.server.Router().ServerFile(g.prefix+path, fileroot)
-	node.Node().groupMiddlewares = g.middlewares
-	return node
+	return g.router.registerServerFile(RouteMethod_GET, g.prefix+path, fileroot, nil, g)
 }
 
 // Group creates a new sub-group with prefix and optional sub-group-level middleware.
 func (g *xGroup) Group(prefix string, m ...Middleware) Group {
-	return NewGroup(g.prefix+prefix, g.server).Use(g.middlewares...).Use(m...)
+	child := newGroup(g.prefix+prefix, g.server, g.router)
+	child.version, child.versionMatcher, child.versionParent = g.version, g.versionMatcher, g.versionParent
+	return child.Use(g.middlewares...).Use(m...)
+}
+
+// Version creates a group with the same prefix and middlewares,
+// routes registered on it share method and path with the default routes,
+// and are picked when matcher returns the version.
+func (g *xGroup) Version(version string, matcher VersionMatcher) Group {
+	if version == "" || matcher == nil {
+		panic("version and matcher must not be empty")
+	}
+	if g.version != "" {
+		panic("group is already a version group of '" + g.version + "'")
+	}
+	vg := newGroup(g.prefix, g.server, g.router)
+	vg.version = version
+	vg.versionMatcher = matcher
+	vg.versionParent = g
+	return vg.Use(g.middlewares...)
+}
+
+// SetDefaultVersion sets the version used when request version matches no variant,
+// if not set, the route registered without version is used.
+func (g *xGroup) SetDefaultVersion(version string) Group {
+	g.defaultVersion = version
+	return g
+}
+
+// Mount forwards all requests below g.prefix+prefix to handler with the prefix stripped,
+// group middlewares still wrap the mounted handler.
+func (g *xGroup) Mount(prefix string, handler http.Handler) Group {
+	for _, path := range g.router.mount(g.prefix+prefix, handler, g) {
+		for _, method := range getAnyMethods() {
+			g.allRouterExpress[method+routerExpressSplit+path] = struct{}{}
+		}
+	}
+	return g
 }
 
 func (g *xGroup) RegisterRoute(method, path string, handler HttpHandle) RouterNode {
@@ -130,10 +192,8 @@ func (g *xGroup) RegisterRoute(method, path string, handler HttpHandle) RouterNo
 }
 
 func (g *xGroup) add(method, path string, handler HttpHandle) RouterNode {
-	node := g.server.Router().RegisterRoute(method, g.prefix+path, handler)
 	g.allRouterExpress[method+routerExpressSplit+g.prefix+path] = struct{}{}
-	node.Node().groupMiddlewares = g.middlewares
-	return node
+	return g.router.registerRoute(method, g.prefix+path, handler, g)
 }
 
 // SetNotFoundHandle sets a custom 404 handler for this group.
@@ -146,3 +206,18 @@ func (g *xGroup) SetNotFoundHandle(handler StandardHandle) Group {
 	g.notFoundHandler = handler
 	return g
 }
+
+// SetMethodNotAllowedHandle sets custom 405 handler for this group.
+// This handler takes priority over the app-level MethodNotAllowedHandler,
+// the Allow header is already set when it is called.
+func (g *xGroup) SetMethodNotAllowedHandle(handler StandardHandle) Group {
+	g.methodNotAllowedHandler = handler
+	return g
+}
+
+// SetOptionsHandle sets custom handler for OPTIONS request of this group
+// which has no OPTIONS route registered, the Allow header is already set when it is called.
+func (g *xGroup) SetOptionsHandle(handler StandardHandle) Group {
+	g.optionsHandler = handler
+	return g
+}
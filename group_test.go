@@ -173,3 +173,103 @@ func (ho testHttpWriter) Write(byte []byte) (int, error) {
 
 func (ho testHttpWriter) WriteHeader(code int) {
 }
+
+// TestGroupSetMethodNotAllowedAndOptionsHandle tests group 405 and OPTIONS handlers
+func TestGroupSetMethodNotAllowedAndOptionsHandle(t *testing.T) {
+	app := New()
+	api := app.HttpServer.Group("/api")
+	api.GET("/user", func(ctx Context) error {
+		return ctx.WriteString("user")
+	})
+	api.SetMethodNotAllowedHandle(func(ctx Context) {
+		ctx.WriteStringC(http.StatusMethodNotAllowed, "api 405")
+	})
+	api.SetOptionsHandle(func(ctx Context) {
+		ctx.WriteStringC(http.StatusNoContent, "")
+	})
+	app.HttpServer.GET("/web", func(ctx Context) error {
+		return ctx.WriteString("web")
+	})
+	app.HttpServer.SetEnabledAutoCORSPreflight(true)
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "POST", "/api/user", nil)
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "api 405" {
+		t.Errorf("expected group 405 handler, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get(HeaderAllow) != "GET, OPTIONS" {
+		t.Errorf("expected Allow header, got %q", w.Header().Get(HeaderAllow))
+	}
+
+	w = doTestRequest(app, "POST", "/web", nil)
+	if w.Body.String() == "api 405" {
+		t.Errorf("expected app 405 handler for path out of group")
+	}
+
+	w = doTestRequest(app, "OPTIONS", "/api/user", nil)
+	if w.Code != http.StatusNoContent || w.Header().Get(HeaderAccessControlAllowMethods) != "" {
+		t.Errorf("expected group OPTIONS handler, got %d %v", w.Code, w.Header())
+	}
+
+	preflight := http.Header{
+		HeaderOrigin:                      []string{"http://example.com"},
+		HeaderAccessControlRequestMethod:  []string{"GET"},
+		HeaderAccessControlRequestHeaders: []string{"X-Token"},
+	}
+	// no CORS header without CorsMiddleware
+	w = doTestRequest(app, "OPTIONS", "/web", preflight)
+	if w.Header().Get(HeaderAccessControlAllowOrigin) != "" || w.Header().Get(HeaderAllow) != "GET, OPTIONS" {
+		t.Errorf("unexpected CORS preflight headers without CorsMiddleware %v", w.Header())
+	}
+
+	app.HttpServer.GET("/cors", func(ctx Context) error {
+		return ctx.WriteString("cors")
+	}).Use(NewCorsMiddleware(CorsConfig{AllowOrigins: []string{"http://example.com"}, AllowHeaders: []string{"X-Token"}}))
+	w = doTestRequest(app, "OPTIONS", "/cors", preflight)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for CORS preflight, got %d", w.Code)
+	}
+	if w.Header().Get(HeaderAccessControlAllowMethods) != "GET, OPTIONS" ||
+		w.Header().Get(HeaderAccessControlAllowOrigin) != "http://example.com" ||
+		w.Header().Get(HeaderAccessControlAllowHeaders) != "X-Token" {
+		t.Errorf("unexpected CORS preflight headers %v", w.Header())
+	}
+	preflight.Set(HeaderOrigin, "http://evil.com")
+	w = doTestRequest(app, "OPTIONS", "/cors", preflight)
+	if w.Code != http.StatusForbidden || w.Header().Get(HeaderAccessControlAllowOrigin) != "" {
+		t.Errorf("expected 403 for origin not allowed, got %d %v", w.Code, w.Header())
+	}
+}
+
+// TestRouteSetMethodNotAllowedAndOptionsHandle tests route 405 and OPTIONS handlers
+func TestRouteSetMethodNotAllowedAndOptionsHandle(t *testing.T) {
+	app := New()
+	api := app.HttpServer.Group("/api")
+	api.SetMethodNotAllowedHandle(func(ctx Context) {
+		ctx.WriteStringC(http.StatusMethodNotAllowed, "api 405")
+	})
+	api.GET("/user/:id", func(ctx Context) error {
+		return ctx.WriteString("user")
+	}).SetMethodNotAllowedHandle(func(ctx Context) {
+		ctx.WriteStringC(http.StatusMethodNotAllowed, "user 405")
+	}).SetOptionsHandle(func(ctx Context) {
+		ctx.WriteStringC(http.StatusOK, "user options")
+	})
+	api.GET("/order", func(ctx Context) error {
+		return ctx.WriteString("order")
+	})
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "DELETE", "/api/user/1", nil)
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "user 405" {
+		t.Errorf("expected route 405 handler, got %d %q", w.Code, w.Body.String())
+	}
+	w = doTestRequest(app, "DELETE", "/api/order", nil)
+	if w.Body.String() != "api 405" {
+		t.Errorf("expected group 405 handler, got %q", w.Body.String())
+	}
+	w = doTestRequest(app, "OPTIONS", "/api/user/1", nil)
+	if w.Body.String() != "user options" || w.Header().Get(HeaderAllow) != "GET, OPTIONS" {
+		t.Errorf("expected route OPTIONS handler, got %q %v", w.Body.String(), w.Header())
+	}
+}
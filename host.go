@@ -0,0 +1,122 @@
+package dotweb
+
+import (
+	"net"
+	"strings"
+)
+
+// hostRoute is a router bound to request host
+// pattern can be exact host like "api.example.com"
+// or contains param labels like "{tenant}.example.com"
+type hostRoute struct {
+	pattern string
+	labels  []string
+	hasWild bool
+	router  *router
+	group   *xGroup
+}
+
+// Host return a group which only serve requests with the given host.
+// The host can contain param labels like "{tenant}.example.com", each param matches one label,
+// captured values can be read by Context.GetRouterName / Context.RouterParams.
+// The returned group has its own router tree, middlewares and NotFound handler,
+// call Host with the same host again will return the same group.
+func (server *HttpServer) Host(host string) Group {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		panic("host must not be empty")
+	}
+	h := &hostRoute{labels: strings.Split(host, ".")}
+	for i, label := range h.labels {
+		if isHostParamLabel(label) {
+			if len(label) == 2 {
+				panic("host param must have a name in '" + host + "'")
+			}
+			h.hasWild = true
+		} else if strings.ContainsAny(label, "{}") {
+			panic("host param must be a whole label in '" + host + "'")
+		} else {
+			h.labels[i] = strings.ToLower(label)
+		}
+	}
+	h.pattern = strings.Join(h.labels, ".")
+	for _, exists := range server.hosts {
+		if exists.pattern == h.pattern {
+			return exists.group
+		}
+	}
+
+	// host router share named routes and handlers with the main router
+	main := server.router.(*router)
+	h.router = NewRouter(server)
+	h.router.host = h.pattern
+	h.router.namedRoutes = main.namedRoutes
+	h.router.namedMutex = main.namedMutex
+	h.router.handlerMap = main.handlerMap
+	h.router.handlerMutex = main.handlerMutex
+	h.group = newGroup("", server, h.router)
+	server.hosts = append(server.hosts, h)
+	server.Logger().Debug("DotWeb:HttpServer Host ["+h.pattern+"]", LogTarget_HttpServer)
+	return h.group
+}
+
+// routerForHost return router which match the request host, and the captured host params.
+// exact host has priority over host with params, fall back to main router if no host match.
+func (server *HttpServer) routerForHost(host string) (Router, Params) {
+	if len(server.hosts) == 0 {
+		return server.router, nil
+	}
+	host = normalizeHost(host)
+	for _, h := range server.hosts {
+		if !h.hasWild && h.pattern == host {
+			return h.router, nil
+		}
+	}
+	labels := strings.Split(host, ".")
+	for _, h := range server.hosts {
+		if !h.hasWild {
+			continue
+		}
+		if ps, ok := h.match(labels); ok {
+			return h.router, ps
+		}
+	}
+	return server.router, nil
+}
+
+// match check host labels, return captured params
+func (h *hostRoute) match(labels []string) (Params, bool) {
+	if len(labels) != len(h.labels) {
+		return nil, false
+	}
+	var ps Params
+	for i, label := range h.labels {
+		if isHostParamLabel(label) {
+			if labels[i] == "" {
+				return nil, false
+			}
+			ps = append(ps, Param{Key: label[1 : len(label)-1], Value: labels[i]})
+		} else if label != labels[i] {
+			return nil, false
+		}
+	}
+	return ps, true
+}
+
+// isHostParamLabel check label is like "{name}"
+func isHostParamLabel(label string) bool {
+	return len(label) >= 2 && label[0] == '{' && label[len(label)-1] == '}'
+}
+
+// normalizeHost lower host and remove port
+func normalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,46 @@
+package dotweb
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestHost_Routing(t *testing.T) {
+	app := New()
+	app.HttpServer.GET("/", func(ctx Context) error {
+		return ctx.WriteString("main")
+	})
+	api := app.HttpServer.Host("api.example.com")
+	api.GET("/", func(ctx Context) error {
+		return ctx.WriteString("api")
+	})
+	api.SetNotFoundHandle(func(ctx Context) {
+		ctx.WriteStringC(http.StatusNotFound, "api not found")
+	})
+	tenant := app.HttpServer.Host("{tenant}.example.com")
+	tenant.GET("/user/:id", func(ctx Context) error {
+		return ctx.WriteString(ctx.GetRouterName("tenant") + ":" + ctx.GetRouterName("id"))
+	})
+	test.Equal(t, api, app.HttpServer.Host("API.example.com:8080"))
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "http://example.com/", nil)
+	test.Equal(t, "main", w.Body.String())
+
+	w = doTestRequest(app, "GET", "http://api.example.com:8080/", nil)
+	test.Equal(t, "api", w.Body.String())
+
+	w = doTestRequest(app, "GET", "http://api.example.com/none", nil)
+	test.Equal(t, http.StatusNotFound, w.Code)
+	test.Equal(t, "api not found", w.Body.String())
+
+	w = doTestRequest(app, "GET", "http://acme.example.com/user/3", nil)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "acme:3", w.Body.String())
+
+	// param label only match one label
+	w = doTestRequest(app, "GET", "http://a.b.example.com/user/3", nil)
+	test.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,224 @@
+package dotweb
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+)
+
+type (
+	// HTTPError is the error with http status, it can be returned by HttpHandle or middleware,
+	// DefaultHTTPErrorHandler reply it with Code as text, RFC 7807 problem+json or html by request Accept
+	HTTPError struct {
+		// Code is the http status code
+		Code int
+		// Message is shown to client, default is the status text
+		Message string
+		// Internal is the underlying error, it is only shown in development mode
+		Internal error
+		// Details is extra data shown to client, e.g. invalid fields
+		Details interface{}
+	}
+
+	// problemDetails is the body of application/problem+json, see RFC 7807
+	problemDetails struct {
+		Type     string      `json:"type"`
+		Title    string      `json:"title"`
+		Status   int         `json:"status"`
+		Detail   string      `json:"detail,omitempty"`
+		Instance string      `json:"instance,omitempty"`
+		Details  interface{} `json:"details,omitempty"`
+		Internal string      `json:"internal,omitempty"`
+	}
+
+	// validationDetail is the detail of invalid field in HTTPError.Details
+	validationDetail struct {
+		Field   string `json:"field"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}
+)
+
+// error body formats chosen by Accept, plain text is kept for clients without preference
+var httpErrorFormats = []string{MIMETextPlain, MIMEApplicationProblemJSON, MIMEApplicationJSON, MIMETextHTML}
+
+// NewHTTPError create HTTPError with status code, the message is status text if not set
+func NewHTTPError(code int, message ...string) *HTTPError {
+	he := &HTTPError{Code: code, Message: http.StatusText(code)}
+	if len(message) > 0 {
+		he.Message = message[0]
+	}
+	return he
+}
+
+// Error return the code, message and internal error
+func (he *HTTPError) Error() string {
+	msg := "code=" + strconv.Itoa(he.Code) + ", message=" + he.Message
+	if he.Internal != nil {
+		msg += ", internal=" + he.Internal.Error()
+	}
+	return msg
+}
+
+// Unwrap return the internal error
+func (he *HTTPError) Unwrap() error {
+	return he.Internal
+}
+
+// WithInternal return a copy of HTTPError with internal error
+func (he *HTTPError) WithInternal(err error) *HTTPError {
+	c := *he
+	c.Internal = err
+	return &c
+}
+
+// WithDetails return a copy of HTTPError with details
+func (he *HTTPError) WithDetails(details interface{}) *HTTPError {
+	c := *he
+	c.Details = details
+	return &c
+}
+
+// toHTTPError convert error to HTTPError, known errors of dotweb are mapped to their status,
+// others are internal server error
+func toHTTPError(err error) *HTTPError {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	// request body is over the limit
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge).WithInternal(err)
+	}
+	// no decoder for request Content-Type
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		return NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+	}
+	// no encoder for request Accept
+	if errors.Is(err, ErrNotAcceptable) {
+		return NewHTTPError(http.StatusNotAcceptable, err.Error())
+	}
+	// struct is invalid, e.g. validated after Bind
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		details := make([]validationDetail, 0, len(validationErrs))
+		for _, e := range validationErrs {
+			details = append(details, validationDetail{Field: e.Field, Rule: e.Tag, Message: e.Message})
+		}
+		return NewHTTPError(http.StatusUnprocessableEntity, validationErrs.Error()).WithDetails(details)
+	}
+	// request body can not be decoded
+	if isDecodeError(err) {
+		return NewHTTPError(http.StatusBadRequest, err.Error()).WithInternal(err)
+	}
+	return NewHTTPError(http.StatusInternalServerError).WithInternal(err)
+}
+
+// isDecodeError check err is returned by decoding invalid request body
+func isDecodeError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var xmlErr *xml.SyntaxError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &xmlErr) ||
+		errors.Is(err, ErrJsonTooDeep) || errors.Is(err, ErrJsonTooManyElements) || errors.Is(err, ErrJsonTrailingData)
+}
+
+// errorFormat return the most acceptable error body format, plain text is used if nothing matches
+func errorFormat(accept string) string {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return MIMETextPlain
+	}
+	best := MIMETextPlain
+	var bestRange *acceptRange
+	bestSpecificity := -1
+	for _, format := range httpErrorFormats {
+		ar, specificity := matchAccept(ranges, format)
+		if ar == nil || ar.q <= 0 {
+			continue
+		}
+		if bestRange == nil || ar.q > bestRange.q || (ar.q == bestRange.q && specificity > bestSpecificity) {
+			best, bestRange, bestSpecificity = format, ar, specificity
+		}
+	}
+	return best
+}
+
+// writeHTTPError reply HTTPError in the format acceptable by request,
+// the internal error and stack are only written in development mode
+func (app *DotWeb) writeHTTPError(ctx Context, he *HTTPError) {
+	var internal string
+	if app.IsDevelopmentMode() && he.Internal != nil {
+		internal = he.Internal.Error()
+		if he.Code == http.StatusInternalServerError {
+			internal = fmt.Sprintln(he.Internal) + string(debug.Stack())
+		}
+	}
+	title := http.StatusText(he.Code)
+	h := ctx.Response().Header()
+	addVary(h, HeaderAccept)
+	switch errorFormat(ctx.Request().Header.Get(HeaderAccept)) {
+	case MIMEApplicationProblemJSON, MIMEApplicationJSON:
+		problem := problemDetails{
+			Type:     "about:blank",
+			Title:    title,
+			Status:   he.Code,
+			Instance: ctx.Request().URL.Path,
+			Details:  he.Details,
+			Internal: internal,
+		}
+		if he.Message != title {
+			problem.Detail = he.Message
+		}
+		b, err := json.Marshal(problem)
+		if err != nil {
+			// details can not be encoded
+			problem.Details = nil
+			b, _ = json.Marshal(problem)
+		}
+		ctx.WriteBlobC(he.Code, MIMEApplicationProblemJSON, b)
+	case MIMETextHTML:
+		detail := ""
+		if he.Message != title {
+			detail = he.Message
+		}
+		ctx.WriteHtmlC(he.Code, errorPage(title, detail, internal))
+	default:
+		h.Set(HeaderContentType, CharsetUTF8)
+		if he.Code == http.StatusInternalServerError && internal != "" {
+			ctx.WriteStringC(he.Code, internal)
+			return
+		}
+		text := he.Message
+		if internal != "" {
+			text += "\n" + internal
+		}
+		ctx.WriteStringC(he.Code, text)
+	}
+}
+
+// errorPage return simple html page of error, detail and stack are escaped
+func errorPage(title, detail, stack string) string {
+	title = html.EscapeString(title)
+	page := "<html><head><title>" + title + "</title></head><body><h1>" + title + "</h1>"
+	if detail != "" {
+		page += "<p>" + html.EscapeString(detail) + "</p>"
+	}
+	if stack != "" {
+		page += "<pre>" + html.EscapeString(stack) + "</pre>"
+	}
+	return page + "</body></html>"
+}
+
+// countError add error count of request, labeled by the status of error
+func countError(ctx Context, err error) {
+	state := ctx.HttpServer().StateInfo()
+	state.AddErrorCount(ctx.Request().Path(), err, 1)
+	state.AddErrorCodeCount(toHTTPError(err).Code, 1)
+}
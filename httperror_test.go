@@ -0,0 +1,109 @@
+package dotweb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestHTTPError(t *testing.T) {
+	he := NewHTTPError(http.StatusNotFound)
+	test.Equal(t, "Not Found", he.Message)
+	internal := errors.New("no rows")
+	he = NewHTTPError(http.StatusNotFound, "user not found").WithInternal(internal)
+	test.Equal(t, "code=404, message=user not found, internal=no rows", he.Error())
+	test.Equal(t, true, errors.Is(he, internal))
+}
+
+func TestDefaultHTTPErrorHandler_HTTPError(t *testing.T) {
+	app := New()
+	app.HttpServer.GET("/user", func(ctx Context) error {
+		return NewHTTPError(http.StatusNotFound, "user <1> not found").WithInternal(errors.New("no rows"))
+	})
+	app.HttpServer.POST("/user", func(ctx Context) error {
+		return NewHTTPError(http.StatusUnprocessableEntity).WithDetails(map[string]string{"name": "required"})
+	})
+	app.HttpServer.GET("/fail", func(ctx Context) error {
+		return errors.New("db is down")
+	})
+	prepareTestApp(app)
+
+	// plain text without Accept, internal error is hidden in production mode
+	w := doTestRequest(app, "GET", "/user", nil)
+	test.Equal(t, http.StatusNotFound, w.Code)
+	test.Equal(t, "user <1> not found", w.Body.String())
+
+	w = doTestRequest(app, "POST", "/user", http.Header{HeaderAccept: {"application/json"}})
+	test.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	test.Equal(t, MIMEApplicationProblemJSON, w.Header().Get(HeaderContentType))
+	test.Equal(t, `{"type":"about:blank","title":"Unprocessable Entity","status":422,"instance":"/user","details":{"name":"required"}}`, w.Body.String())
+
+	w = doTestRequest(app, "GET", "/user", http.Header{HeaderAccept: {"text/html,application/xhtml+xml,*/*;q=0.8"}})
+	test.Equal(t, http.StatusNotFound, w.Code)
+	test.Equal(t, "<html><head><title>Not Found</title></head><body><h1>Not Found</h1><p>user &lt;1&gt; not found</p></body></html>", w.Body.String())
+
+	w = doTestRequest(app, "GET", "/fail", http.Header{HeaderAccept: {MIMEApplicationProblemJSON}})
+	test.Equal(t, http.StatusInternalServerError, w.Code)
+	test.Equal(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/fail"}`, w.Body.String())
+
+	// internal error is shown in development mode
+	app.Config.App.RunMode = RunMode_Development
+	w = doTestRequest(app, "GET", "/user", http.Header{HeaderAccept: {"application/json"}})
+	test.Equal(t, true, strings.HasSuffix(w.Body.String(), `"detail":"user \u003c1\u003e not found","instance":"/user","internal":"no rows"}`))
+	w = doTestRequest(app, "GET", "/fail", nil)
+	test.Equal(t, true, strings.HasPrefix(w.Body.String(), "db is down\n"))
+
+	state := app.HttpServer.StateInfo()
+	test.Equal(t, uint64(3), state.DetailErrorCodeData.GetUInt64("404"))
+	test.Equal(t, uint64(1), state.DetailErrorCodeData.GetUInt64("422"))
+	test.Equal(t, uint64(2), state.DetailErrorCodeData.GetUInt64("500"))
+}
+
+func TestDefaultHTTPErrorHandler_BindError(t *testing.T) {
+	app := New()
+	app.HttpServer.Validator = NewValidator()
+	app.HttpServer.SetEnabledBindValidate(true)
+	app.HttpServer.POST("/user", func(ctx Context) error {
+		user := &testValidateUser{}
+		if err := ctx.Bind(user); err != nil {
+			return err
+		}
+		return ctx.WriteString(user.Name)
+	})
+	app.HttpServer.POST("/stream", func(ctx Context) error {
+		user := &testValidateUser{}
+		if err := ctx.BindJsonStream(user); err != nil {
+			return err
+		}
+		return ctx.WriteString(user.Name)
+	})
+	prepareTestApp(app)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		req.Header.Set(HeaderAccept, MIMEApplicationProblemJSON)
+		w := httptest.NewRecorder()
+		app.HttpServer.ServeHTTP(w, req)
+		return w
+	}
+	w := post("/user", `{"Name":"dotweb","Role":"user","Age":10}`)
+	test.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	test.Equal(t, `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"Age must be at least 18","instance":"/user",`+
+		`"details":[{"field":"Age","rule":"min","message":"Age must be at least 18"}]}`, w.Body.String())
+
+	w = post("/user", `{"Name":`)
+	test.Equal(t, http.StatusBadRequest, w.Code)
+	test.Equal(t, true, strings.Contains(w.Body.String(), `"detail":"unexpected end of JSON input"`))
+
+	w = post("/user", `{"Name":"dotweb","Age":"20"}`)
+	test.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = post("/stream", `{"Name":"dotweb","Role":"user","Age":20} {}`)
+	test.Equal(t, http.StatusBadRequest, w.Code)
+	test.Equal(t, true, strings.Contains(w.Body.String(), ErrJsonTrailingData.Error()))
+}
@@ -0,0 +1,465 @@
+package dotweb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devfeel/dotweb/core"
+)
+
+// HubDropPolicy decide what to do when the send queue of a connection is full
+type HubDropPolicy int
+
+const (
+	// HubDisconnect close the slow connection, it is the default policy
+	HubDisconnect HubDropPolicy = iota
+	// HubDropNewest drop the message being sent
+	HubDropNewest
+	// HubDropOldest drop the oldest message in queue to make room
+	HubDropOldest
+	// HubBlock block the sender until queue has room, the connection is closed after HubConfig.SendTimeout
+	HubBlock
+)
+
+const (
+	defaultHubQueueSize   = 256
+	defaultHubSendTimeout = 10 * time.Second
+)
+
+var (
+	// ErrHubClosed is returned when register connection to a closed hub
+	ErrHubClosed = errors.New("hub: hub is closed")
+	// ErrHubQueueFull is returned by HubConn.Send when the message is dropped or connection is closed for full queue
+	ErrHubQueueFull = errors.New("hub: send queue is full")
+)
+
+type (
+	// HubConfig is the config of Hub
+	HubConfig struct {
+		// QueueSize is the size of send queue of each connection
+		QueueSize int
+		// DropPolicy decide what to do when the send queue is full
+		DropPolicy HubDropPolicy
+		// SendTimeout is the max waiting time of HubBlock, zero means 10 seconds,
+		// so one stalled connection can not block Broadcast forever
+		SendTimeout time.Duration
+	}
+
+	// Hub is a registry of websocket connections, connections can join rooms and receive broadcast messages.
+	// Each connection has a send queue written by its own goroutine, so slow clients do not block others
+	Hub struct {
+		name   string
+		config HubConfig
+		state  *core.ServerStateInfo
+		mutex  sync.RWMutex
+		conns  map[*HubConn]struct{}
+		rooms  map[string]map[*HubConn]struct{}
+		closed bool
+	}
+
+	// HubConn is a websocket connection registered in Hub
+	HubConn struct {
+		hub     *Hub
+		ws      *WebSocket
+		ctx     Context
+		queue   chan hubMessage
+		rooms   map[string]struct{}
+		dropped uint64
+		// done is closed when the connection is closing, closeCode and drain are set before
+		done      chan struct{}
+		closeOnce sync.Once
+		closeCode int
+		drain     bool
+		stopped   chan struct{}
+	}
+
+	hubMessage struct {
+		messageType int
+		data        []byte
+	}
+)
+
+// DefaultHubConfig return the default config, queue size is 256 and slow connection is closed
+func DefaultHubConfig() HubConfig {
+	return HubConfig{QueueSize: defaultHubQueueSize, DropPolicy: HubDisconnect}
+}
+
+// NewHub create and register a websocket hub with name, it is closed when DotWeb.Shutdown,
+// the connection count is shown in server state with the name
+func (app *DotWeb) NewHub(name string, config HubConfig) *Hub {
+	app.hubMutex.Lock()
+	defer app.hubMutex.Unlock()
+	if _, exists := app.hubMap[name]; exists {
+		panic("dotweb: hub [" + name + "] is already registered")
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultHubQueueSize
+	}
+	if config.DropPolicy == HubBlock && config.SendTimeout <= 0 {
+		config.SendTimeout = defaultHubSendTimeout
+	}
+	hub := &Hub{
+		name:   name,
+		config: config,
+		state:  app.serverStateInfo,
+		conns:  make(map[*HubConn]struct{}),
+		rooms:  make(map[string]map[*HubConn]struct{}),
+	}
+	app.hubMap[name] = hub
+	hub.state.SetHubCount(name, 0)
+	app.Logger().Debug("DotWeb NewHub ["+name+"]", LogTarget_HttpServer)
+	return hub
+}
+
+// Hub return the hub registered with name, nil if not exists
+func (app *DotWeb) Hub(name string) *Hub {
+	app.hubMutex.RLock()
+	defer app.hubMutex.RUnlock()
+	return app.hubMap[name]
+}
+
+// closeHubs close all hubs, used by Shutdown, connections not drained before ctx done are closed immediately
+func (app *DotWeb) closeHubs(ctx context.Context) {
+	app.hubMutex.RLock()
+	defer app.hubMutex.RUnlock()
+	for _, hub := range app.hubMap {
+		if err := hub.Shutdown(ctx); err != nil {
+			app.Logger().Warn("DotWeb Hub ["+hub.name+"] shutdown error: "+err.Error(), LogTarget_HttpServer)
+		}
+	}
+}
+
+// Name return the hub name
+func (h *Hub) Name() string {
+	return h.name
+}
+
+// Register add the websocket of ctx to hub and start its send goroutine,
+// it must be called in websocket handler, and HubConn.Close should be called before handler returns
+func (h *Hub) Register(ctx Context) (*HubConn, error) {
+	ws := ctx.WebSocket()
+	if ws == nil {
+		return nil, errors.New("hub: not a websocket request")
+	}
+	conn := &HubConn{
+		hub:     h,
+		ws:      ws,
+		ctx:     ctx,
+		queue:   make(chan hubMessage, h.config.QueueSize),
+		rooms:   make(map[string]struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	h.mutex.Lock()
+	if h.closed {
+		h.mutex.Unlock()
+		return nil, ErrHubClosed
+	}
+	h.conns[conn] = struct{}{}
+	h.state.SetHubCount(h.name, len(h.conns))
+	h.mutex.Unlock()
+	go conn.writeLoop()
+	return conn, nil
+}
+
+// Serve register the websocket of ctx and pass received messages to onMessage until the connection closed,
+// the connection is removed from hub when it returns, it is usually returned by websocket handler directly
+func (h *Hub) Serve(ctx Context, onMessage func(conn *HubConn, messageType int, data []byte) error) error {
+	conn, err := h.Register(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		messageType, data, err := conn.ws.NextMessage()
+		if err != nil {
+			// closed by client, hub or read deadline
+			return nil
+		}
+		if onMessage == nil {
+			continue
+		}
+		if err = onMessage(conn, messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+// Broadcast send message to all connections, return the count of connections which the message is queued to
+func (h *Hub) Broadcast(messageType int, data []byte) int {
+	h.mutex.RLock()
+	conns := make([]*HubConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mutex.RUnlock()
+	return sendToAll(conns, messageType, data)
+}
+
+// BroadcastRoom send message to connections in room, return the count of connections which the message is queued to
+func (h *Hub) BroadcastRoom(room string, messageType int, data []byte) int {
+	h.mutex.RLock()
+	conns := make([]*HubConn, 0, len(h.rooms[room]))
+	for c := range h.rooms[room] {
+		conns = append(conns, c)
+	}
+	h.mutex.RUnlock()
+	return sendToAll(conns, messageType, data)
+}
+
+func sendToAll(conns []*HubConn, messageType int, data []byte) int {
+	count := 0
+	for _, c := range conns {
+		if c.Send(messageType, data) == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Count return the count of connections
+func (h *Hub) Count() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.conns)
+}
+
+// RoomCount return the count of connections in room
+func (h *Hub) RoomCount(room string) int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Rooms return the names of rooms which have connections, sorted by name
+func (h *Hub) Rooms() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	rooms := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Close close all connections with CloseGoingAway after queued messages sent, no connection can be registered after close
+func (h *Hub) Close() {
+	h.Shutdown(context.Background())
+}
+
+// Shutdown close the hub like Close, but connections still sending queued messages when ctx done
+// are closed immediately, and ctx.Err() is returned
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mutex.Lock()
+	h.closed = true
+	conns := make([]*HubConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mutex.Unlock()
+	for _, c := range conns {
+		c.close(CloseGoingAway, true)
+	}
+	for i, c := range conns {
+		select {
+		case <-c.stopped:
+		case <-ctx.Done():
+			// stop the writing of stalled clients
+			for _, c := range conns[i:] {
+				c.ws.closeConn()
+			}
+			for _, c := range conns[i:] {
+				<-c.stopped
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// remove remove the connection from hub and all rooms
+func (h *Hub) remove(c *HubConn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, exists := h.conns[c]; !exists {
+		return
+	}
+	delete(h.conns, c)
+	for room := range c.rooms {
+		h.leave(c, room)
+	}
+	h.state.SetHubCount(h.name, len(h.conns))
+}
+
+// leave remove the connection from room, the room is removed when empty, hub mutex must be held
+func (h *Hub) leave(c *HubConn, room string) {
+	delete(c.rooms, room)
+	if members, exists := h.rooms[room]; exists {
+		delete(members, c)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// WebSocket return the websocket of connection
+func (c *HubConn) WebSocket() *WebSocket {
+	return c.ws
+}
+
+// Context return the context of websocket handler
+func (c *HubConn) Context() Context {
+	return c.ctx
+}
+
+// Join add the connection to room
+func (c *HubConn) Join(room string) {
+	h := c.hub
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, exists := h.conns[c]; !exists {
+		return
+	}
+	members, exists := h.rooms[room]
+	if !exists {
+		members = make(map[*HubConn]struct{})
+		h.rooms[room] = members
+	}
+	members[c] = struct{}{}
+	c.rooms[room] = struct{}{}
+}
+
+// Leave remove the connection from room
+func (c *HubConn) Leave(room string) {
+	c.hub.mutex.Lock()
+	defer c.hub.mutex.Unlock()
+	c.hub.leave(c, room)
+}
+
+// Rooms return the rooms which the connection joined, sorted by name
+func (c *HubConn) Rooms() []string {
+	c.hub.mutex.RLock()
+	defer c.hub.mutex.RUnlock()
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Dropped return the count of messages dropped by drop policy
+func (c *HubConn) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// Send put message into the send queue, the drop policy is applied when queue is full
+func (c *HubConn) Send(messageType int, data []byte) error {
+	msg := hubMessage{messageType: messageType, data: data}
+	select {
+	case <-c.done:
+		return ErrWebSocketClosed
+	default:
+	}
+	select {
+	case c.queue <- msg:
+		return nil
+	default:
+	}
+	switch c.hub.config.DropPolicy {
+	case HubDropNewest:
+		atomic.AddUint64(&c.dropped, 1)
+		return ErrHubQueueFull
+	case HubDropOldest:
+		for {
+			select {
+			case <-c.queue:
+				atomic.AddUint64(&c.dropped, 1)
+			default:
+			}
+			select {
+			case c.queue <- msg:
+				return nil
+			case <-c.done:
+				return ErrWebSocketClosed
+			default:
+			}
+		}
+	case HubBlock:
+		timer := time.NewTimer(c.hub.config.SendTimeout)
+		defer timer.Stop()
+		select {
+		case c.queue <- msg:
+			return nil
+		case <-c.done:
+			return ErrWebSocketClosed
+		case <-timer.C:
+			c.close(ClosePolicyViolation, false)
+			return ErrHubQueueFull
+		}
+	default:
+		c.close(ClosePolicyViolation, false)
+		return ErrHubQueueFull
+	}
+}
+
+// Close remove the connection from hub, send queued messages and close the websocket
+func (c *HubConn) Close() {
+	c.hub.remove(c)
+	c.close(CloseNormalClosure, true)
+	<-c.stopped
+}
+
+// close stop the send goroutine, queued messages are sent before close frame if drain is true
+func (c *HubConn) close(code int, drain bool) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.drain = drain
+		close(c.done)
+	})
+}
+
+func (c *HubConn) writeLoop() {
+	defer close(c.stopped)
+	// remove from hub even if handler returns without Close
+	defer c.hub.remove(c)
+	for {
+		select {
+		case msg := <-c.queue:
+			if c.ws.WriteMessage(msg.messageType, msg.data) != nil {
+				c.close(CloseAbnormalClosure, false)
+				c.ws.closeConn()
+				return
+			}
+		case <-c.done:
+			if c.drain {
+				c.flushQueue()
+			}
+			c.ws.Close(c.closeCode, "")
+			return
+		case <-c.ws.stop:
+			// websocket closed by handler return or read error
+			c.close(CloseAbnormalClosure, false)
+			return
+		}
+	}
+}
+
+// flushQueue write the queued messages without waiting new ones
+func (c *HubConn) flushQueue() {
+	for {
+		select {
+		case msg := <-c.queue:
+			if c.ws.WriteMessage(msg.messageType, msg.data) != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package dotweb
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestHub_Broadcast(t *testing.T) {
+	app := New()
+	hub := app.NewHub("chat", DefaultHubConfig())
+	app.HttpServer.WebSocket("/chat", func(ctx Context) error {
+		return hub.Serve(ctx, func(conn *HubConn, messageType int, data []byte) error {
+			msg := string(data)
+			if strings.HasPrefix(msg, "join:") {
+				conn.Join(strings.TrimPrefix(msg, "join:"))
+				return conn.Send(TextMessage, []byte("joined"))
+			}
+			hub.BroadcastRoom("room", messageType, data)
+			return nil
+		})
+	})
+	prepareTestApp(app)
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	readText := func(c *testWebSocketClient) string {
+		_, payload, _ := c.readFrame()
+		return string(payload)
+	}
+	a, _ := dialTestWebSocket(t, server, "/chat", nil)
+	b, _ := dialTestWebSocket(t, server, "/chat", nil)
+	c, _ := dialTestWebSocket(t, server, "/chat", nil)
+	defer a.conn.Close()
+	defer b.conn.Close()
+	defer c.conn.Close()
+	a.writeFrame(true, TextMessage, []byte("join:room"), false)
+	test.Equal(t, "joined", readText(a))
+	b.writeFrame(true, TextMessage, []byte("join:room"), false)
+	test.Equal(t, "joined", readText(b))
+	c.writeFrame(true, TextMessage, []byte("join:other"), false)
+	test.Equal(t, "joined", readText(c))
+
+	test.Equal(t, 3, hub.Count())
+	test.Equal(t, 2, hub.RoomCount("room"))
+	test.Equal(t, []string{"other", "room"}, hub.Rooms())
+	count, _ := app.HttpServer.StateInfo().HubData.Get("chat")
+	test.Equal(t, 3, count)
+	test.Equal(t, uint64(3), app.HttpServer.StateInfo().CurrentWebSocketCount)
+
+	a.writeFrame(true, TextMessage, []byte("hi"), false)
+	test.Equal(t, "hi", readText(a))
+	test.Equal(t, "hi", readText(b))
+	test.Equal(t, 3, hub.Broadcast(TextMessage, []byte("all")))
+	test.Equal(t, "all", readText(a))
+	test.Equal(t, "all", readText(b))
+	test.Equal(t, "all", readText(c))
+
+	// shutdown close connections with going away
+	test.Nil(t, app.Shutdown(context.Background()))
+	test.Equal(t, CloseGoingAway, a.readCloseCode())
+	test.Equal(t, CloseGoingAway, c.readCloseCode())
+	test.Equal(t, 0, hub.Count())
+	test.Equal(t, 0, len(hub.Rooms()))
+	_, err := hub.Register(&HttpContext{webSocket: &WebSocket{}})
+	test.Equal(t, ErrHubClosed, err)
+}
+
+func TestHubConn_DropPolicy(t *testing.T) {
+	app := New()
+	newConn := func(config HubConfig) *HubConn {
+		hub := app.NewHub(t.Name()+strings.Repeat("_", len(app.hubMap)), config)
+		return &HubConn{hub: hub, queue: make(chan hubMessage, 1), done: make(chan struct{})}
+	}
+
+	c := newConn(HubConfig{QueueSize: 1, DropPolicy: HubDropNewest})
+	test.Nil(t, c.Send(TextMessage, []byte("1")))
+	test.Equal(t, ErrHubQueueFull, c.Send(TextMessage, []byte("2")))
+	test.Equal(t, uint64(1), c.Dropped())
+	test.Equal(t, "1", string((<-c.queue).data))
+
+	c = newConn(HubConfig{QueueSize: 1, DropPolicy: HubDropOldest})
+	test.Nil(t, c.Send(TextMessage, []byte("1")))
+	test.Nil(t, c.Send(TextMessage, []byte("2")))
+	test.Equal(t, uint64(1), c.Dropped())
+	test.Equal(t, "2", string((<-c.queue).data))
+
+	c = newConn(HubConfig{QueueSize: 1, DropPolicy: HubBlock, SendTimeout: 10 * time.Millisecond})
+	test.Nil(t, c.Send(TextMessage, []byte("1")))
+	test.Equal(t, ErrHubQueueFull, c.Send(TextMessage, []byte("2")))
+	test.Equal(t, ClosePolicyViolation, c.closeCode)
+
+	// HubBlock never waits forever
+	c = newConn(HubConfig{QueueSize: 1, DropPolicy: HubBlock})
+	test.Equal(t, defaultHubSendTimeout, c.hub.config.SendTimeout)
+
+	c = newConn(HubConfig{QueueSize: 1, DropPolicy: HubDisconnect})
+	test.Nil(t, c.Send(TextMessage, []byte("1")))
+	test.Equal(t, ErrHubQueueFull, c.Send(TextMessage, []byte("2")))
+	test.Equal(t, ErrWebSocketClosed, c.Send(TextMessage, []byte("3")))
+}
+
+func TestHub_ShutdownTimeout(t *testing.T) {
+	app := New()
+	hub := app.NewHub("stalled", DefaultHubConfig())
+	// nobody reads the client side, so writing to the connection blocks
+	server, client := net.Pipe()
+	defer client.Close()
+	ws := &WebSocket{conn: server, stop: make(chan struct{})}
+	conn, err := hub.Register(&HttpContext{webSocket: ws})
+	test.Nil(t, err)
+	test.Nil(t, conn.Send(TextMessage, []byte("stalled")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	test.Equal(t, context.DeadlineExceeded, hub.Shutdown(ctx))
+	test.Equal(t, true, time.Since(begin) < time.Second)
+	test.Equal(t, true, ws.IsClosed())
+	test.Equal(t, 0, hub.Count())
+}
@@ -0,0 +1,166 @@
+package dotweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+const defaultJsonStreamMaxDepth = 100
+
+var (
+	// ErrJsonTooDeep is returned by streaming json decoding when nesting depth is over JsonStreamLimit.MaxDepth
+	ErrJsonTooDeep = errors.New("json: nesting depth exceeds limit")
+	// ErrJsonTooManyElements is returned by streaming json decoding when element count is over JsonStreamLimit.MaxElements
+	ErrJsonTooManyElements = errors.New("json: element count exceeds limit")
+	// ErrJsonTrailingData is returned by BindJsonStream when body has data after the json value
+	ErrJsonTrailingData = errors.New("json: unexpected data after top-level value")
+)
+
+type (
+	// JsonStreamLimit is the limits of Context.BindJsonStream and Context.ReadNDJSON, zero value means no limit
+	JsonStreamLimit struct {
+		// MaxBytes limit the body size, *http.MaxBytesError is returned if exceeded
+		MaxBytes int64
+		// MaxDepth limit the nesting depth of arrays and objects
+		MaxDepth int
+		// MaxElements limit the count of array elements and object members for BindJsonStream,
+		// and the count of records for ReadNDJSON
+		MaxElements int
+		// DisallowUnknownFields reject object keys which do not match any field of struct for BindJsonStream
+		DisallowUnknownFields bool
+	}
+
+	// jsonLimitReader scan json bytes read by json.Decoder, fail the read when depth or element count is over limit
+	jsonLimitReader struct {
+		r           io.Reader
+		maxDepth    int
+		maxElements int
+		depth       int
+		elements    int
+		inString    bool
+		escaped     bool
+		// opened is set after '[' or '{', the next value is the first element
+		opened bool
+		// err is the limit or read error, json.Decoder may not return it as is
+		err error
+	}
+
+	// jsonStream decode json values from request body with limits
+	jsonStream struct {
+		dec   *json.Decoder
+		lr    *jsonLimitReader
+		limit JsonStreamLimit
+	}
+)
+
+// DefaultJsonStreamLimit return the default limit, only MaxDepth is limited
+func DefaultJsonStreamLimit() JsonStreamLimit {
+	return JsonStreamLimit{MaxDepth: defaultJsonStreamMaxDepth}
+}
+
+func (l *jsonLimitReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	for i := 0; i < n; i++ {
+		if scanErr := l.scan(p[i]); scanErr != nil {
+			l.err = scanErr
+			return i, scanErr
+		}
+	}
+	if err != nil && err != io.EOF {
+		l.err = err
+	}
+	return n, err
+}
+
+func (l *jsonLimitReader) scan(c byte) error {
+	if l.inString {
+		switch {
+		case l.escaped:
+			l.escaped = false
+		case c == '\\':
+			l.escaped = true
+		case c == '"':
+			l.inString = false
+		}
+		return nil
+	}
+	if l.opened && !isJsonSpace(c) {
+		l.opened = false
+		if c != ']' && c != '}' {
+			if err := l.addElement(); err != nil {
+				return err
+			}
+		}
+	}
+	switch c {
+	case '"':
+		l.inString = true
+	case '[', '{':
+		l.depth++
+		if l.maxDepth > 0 && l.depth > l.maxDepth {
+			return ErrJsonTooDeep
+		}
+		l.opened = true
+	case ']', '}':
+		l.depth--
+	case ',':
+		if l.depth > 0 {
+			return l.addElement()
+		}
+	}
+	return nil
+}
+
+func (l *jsonLimitReader) addElement() error {
+	l.elements++
+	if l.maxElements > 0 && l.elements > l.maxElements {
+		return ErrJsonTooManyElements
+	}
+	return nil
+}
+
+func isJsonSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// decode decode next json value to v, the limit or read error is returned in priority
+func (s *jsonStream) decode(v interface{}) error {
+	if err := s.dec.Decode(v); err != nil {
+		if s.lr.err != nil {
+			return s.lr.err
+		}
+		return err
+	}
+	return nil
+}
+
+// newJsonStream create jsonStream on request body with the server JsonStreamLimit,
+// countElements is false for NDJSON which limit the count of records
+func (ctx *HttpContext) newJsonStream(countElements bool) *jsonStream {
+	limit := ctx.httpServer.JsonStreamLimit()
+	req := ctx.Request()
+	var body io.Reader = req.Body
+	if req.isReadBody {
+		body = bytes.NewReader(req.postBody)
+	} else if body == nil {
+		body = http.NoBody
+	}
+	if limit.MaxBytes > 0 {
+		body = http.MaxBytesReader(ctx.Response().Writer(), io.NopCloser(body), limit.MaxBytes)
+	}
+	lr := &jsonLimitReader{r: body, maxDepth: limit.MaxDepth}
+	if countElements {
+		lr.maxElements = limit.MaxElements
+	}
+	dec := json.NewDecoder(lr)
+	if limit.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	return &jsonStream{dec: dec, lr: lr, limit: limit}
+}
@@ -0,0 +1,86 @@
+package dotweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestContext_BindJsonStream(t *testing.T) {
+	app := New()
+	app.HttpServer.SetJsonStreamLimit(JsonStreamLimit{MaxDepth: 3, MaxElements: 5, DisallowUnknownFields: true})
+	var bindErr error
+	app.HttpServer.POST("/bind", func(ctx Context) error {
+		v := &struct {
+			Name string        `json:"name"`
+			Tags []interface{} `json:"tags"`
+		}{}
+		if bindErr = ctx.BindJsonStream(v); bindErr != nil {
+			return ctx.WriteStringC(http.StatusBadRequest, bindErr.Error())
+		}
+		return ctx.WriteString(v.Name, len(v.Tags))
+	})
+	prepareTestApp(app)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/bind", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		app.HttpServer.ServeHTTP(w, req)
+		return w
+	}
+	w := post(`{"name":"dotweb","tags":["a",{"b":1}]}` + "\n")
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "dotweb2", w.Body.String())
+
+	post(`{"name":"dotweb","tags":[[["deep"]]]}`)
+	test.Equal(t, true, errors.Is(bindErr, ErrJsonTooDeep))
+
+	post(`{"name":"dotweb","tags":[1,2,3,4,5]}`)
+	test.Equal(t, true, errors.Is(bindErr, ErrJsonTooManyElements))
+
+	post(`{"name":"dotweb","age":1}`)
+	test.NotNil(t, bindErr)
+
+	post(`{"name":"dotweb"} {}`)
+	test.Equal(t, ErrJsonTrailingData, bindErr)
+
+	// brackets in string are not counted
+	w = post(`{"name":"[[[[,,,,,,"}`)
+	test.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestContext_ReadNDJSON(t *testing.T) {
+	app := New()
+	app.HttpServer.SetJsonStreamLimit(JsonStreamLimit{MaxBytes: 64, MaxElements: 3})
+	app.HttpServer.POST("/ingest", func(ctx Context) error {
+		var names []string
+		err := ctx.ReadNDJSON(func(raw json.RawMessage) error {
+			v := struct{ Name string }{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+			names = append(names, v.Name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return ctx.WriteString(strings.Join(names, ","))
+	})
+	prepareTestApp(app)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		app.HttpServer.ServeHTTP(w, req)
+		return w
+	}
+	test.Equal(t, "a,b", post("{\"Name\":\"a\"}\n{\"Name\":\"b\"}\n").Body.String())
+	test.Equal(t, http.StatusBadRequest, post("{}\n{}\n{}\n{}\n").Code)
+	test.Equal(t, http.StatusRequestEntityTooLarge, post(`{"Name":"`+strings.Repeat("x", 100)+`"}`).Code)
+}
@@ -0,0 +1,300 @@
+package dotweb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// route limit names, used as key prefix of ServerStateInfo.RouteLimitData
+const (
+	RouteLimit_MaxBody       = "maxbody"
+	RouteLimit_Timeout       = "timeout"
+	RouteLimit_MaxConcurrent = "maxconcurrent"
+	RouteLimit_RateLimit     = "ratelimit"
+)
+
+// routeLimit hold the request limits of a route,
+// concurrent is shared by the copies of route node, so the concurrent count survive route tree rebuild
+type routeLimit struct {
+	maxBody       int64
+	timeout       time.Duration
+	maxConcurrent int64
+	concurrent    *int64
+}
+
+// MaxBody limit the request body size of the route,
+// request with larger Content-Length is replied 413 before handler called,
+// reading more than size from body return *http.MaxBytesError and cancel Context.Context()
+func (n *Node) MaxBody(size int64) *Node {
+	return n.update(func(route *Node) {
+		route.getLimit().maxBody = size
+	})
+}
+
+// Timeout set the processing timeout of the route, Context.Context() is cancelled when timeout.
+// Like http.TimeoutHandler, the response is buffered until handler returns, 504 is replied at the deadline
+// even if handler ignores Context.Context(), and writes after that return http.ErrHandlerTimeout.
+// Streams which call Flush are sent directly after the first Flush, they are only stopped by Context.Context()
+func (n *Node) Timeout(timeout time.Duration) *Node {
+	return n.update(func(route *Node) {
+		route.getLimit().timeout = timeout
+	})
+}
+
+// MaxConcurrent limit the count of requests processed by the route at the same time,
+// request over the limit is replied 503 immediately
+func (n *Node) MaxConcurrent(max int) *Node {
+	return n.update(func(route *Node) {
+		route.getLimit().maxConcurrent = int64(max)
+	})
+}
+
+func (n *Node) getLimit() *routeLimit {
+	if n.limit == nil {
+		n.limit = &routeLimit{concurrent: new(int64)}
+	}
+	return n.limit
+}
+
+// limitedBody cancel request context when body size is over limit
+type limitedBody struct {
+	io.ReadCloser
+	cancel   context.CancelFunc
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if err != nil && errors.As(err, &maxErr) {
+		b.exceeded = true
+		b.cancel()
+	}
+	return n, err
+}
+
+// serveLimited call handle with the limits of route node
+func (r *router) serveLimited(ctx Context, node *Node, handle RouterHandle) {
+	limit := node.limit
+	if limit.maxConcurrent > 0 {
+		if atomic.AddInt64(limit.concurrent, 1) > limit.maxConcurrent {
+			atomic.AddInt64(limit.concurrent, -1)
+			r.rejectByLimit(ctx, node, RouteLimit_MaxConcurrent, http.StatusServiceUnavailable)
+			return
+		}
+		defer atomic.AddInt64(limit.concurrent, -1)
+	}
+
+	req := ctx.Request()
+	if limit.maxBody > 0 && req.ContentLength > limit.maxBody {
+		r.rejectByLimit(ctx, node, RouteLimit_MaxBody, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if limit.maxBody <= 0 && limit.timeout <= 0 {
+		handle(ctx)
+		return
+	}
+
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if limit.timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx.Context(), limit.timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(ctx.Context())
+	}
+	defer cancel()
+	ctx.WithContext(runCtx)
+	ctx.setCancel(cancel)
+
+	var body *limitedBody
+	if limit.maxBody > 0 && req.Body != nil {
+		body = &limitedBody{ReadCloser: http.MaxBytesReader(ctx.Response().Writer(), req.Body, limit.maxBody), cancel: cancel}
+		req.Body = body
+	}
+
+	if limit.timeout > 0 {
+		if !r.serveTimeout(ctx, node, handle, runCtx) {
+			return
+		}
+	} else {
+		handle(ctx)
+	}
+
+	if body != nil && body.exceeded {
+		r.rejectByLimit(ctx, node, RouteLimit_MaxBody, http.StatusRequestEntityTooLarge)
+	} else if limit.timeout > 0 && runCtx.Err() == context.DeadlineExceeded {
+		r.rejectByLimit(ctx, node, RouteLimit_Timeout, http.StatusGatewayTimeout)
+	}
+}
+
+// serveTimeout run handle with buffered response, and reply 504 at the deadline if handle has not returned,
+// return false if timeout is replied. It always waits handle returns, as ctx is reused after request
+func (r *router) serveTimeout(ctx Context, node *Node, handle RouterHandle, runCtx context.Context) bool {
+	res := ctx.Response()
+	w, header := res.writer, res.header
+	tw := &timeoutWriter{w: w, header: header.Clone()}
+	res.writer, res.header = tw, tw.header
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		handle(ctx)
+	}()
+
+	// runCtx may also be cancelled by MaxBody, so wait the deadline by timer
+	deadline, _ := runCtx.Deadline()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	var p interface{}
+	timeout := false
+	select {
+	case p = <-done:
+	case <-timer.C:
+		tw.mutex.Lock()
+		// the stream is already sent, it stops by cancelled context
+		timeout = !tw.streaming
+		tw.timedOut = timeout
+		tw.mutex.Unlock()
+		if timeout {
+			r.server.StateInfo().AddRouteLimitCount(node.fullPath, RouteLimit_Timeout, 1)
+			writeTimeout(rawWriter(w))
+		}
+		p = <-done
+	}
+	res.writer, res.header = w, header
+	if p != nil {
+		panic(p)
+	}
+	if timeout {
+		// 504 is written without gzip, so the gzip writer is not closed on release
+		res.disableGzip()
+		res.Status = http.StatusGatewayTimeout
+		res.committed = true
+		return false
+	}
+	if !tw.streaming {
+		tw.send()
+	}
+	return true
+}
+
+// rawWriter return the writer wrapped by gzip, or w itself
+func rawWriter(w http.ResponseWriter) http.ResponseWriter {
+	if gw, ok := w.(*gzipResponseWriter); ok {
+		return gw.ResponseWriter
+	}
+	return w
+}
+
+// writeTimeout write 504 to the underlying raw writer and flush it, so client get it before handler returns
+func writeTimeout(w http.ResponseWriter) {
+	text := http.StatusText(http.StatusGatewayTimeout)
+	w.Header().Del(HeaderContentEncoding)
+	w.Header().Set(HeaderContentType, CharsetUTF8)
+	w.Header().Set(HeaderContentLength, strconv.Itoa(len(text)))
+	w.WriteHeader(http.StatusGatewayTimeout)
+	io.WriteString(w, text)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// timeoutWriter buffer the header and body written by handler of route with timeout
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	mutex       sync.Mutex
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+	streaming   bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	if tw.streaming {
+		return tw.w.Write(p)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(code)
+	if tw.streaming {
+		tw.w.WriteHeader(code)
+	}
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	tw.wroteHeader = true
+	tw.code = code
+}
+
+// Flush send the buffered response and stop buffering, so streams can be used with timeout
+func (tw *timeoutWriter) Flush() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.streaming {
+		tw.streaming = true
+		tw.send()
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// send write the buffered header and body to the underlying writer
+func (tw *timeoutWriter) send() {
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, exists := tw.header[k]; !exists {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if tw.wroteHeader {
+		tw.w.WriteHeader(tw.code)
+		tw.w.Write(tw.buf.Bytes())
+		tw.buf.Reset()
+	}
+}
+
+// rejectByLimit record the limit and reply code if response is not written
+func (r *router) rejectByLimit(ctx Context, node *Node, limit string, code int) {
+	r.server.StateInfo().AddRouteLimitCount(node.fullPath, limit, 1)
+	if ctx.Response().committed {
+		return
+	}
+	ctx.Response().Header().Set(HeaderContentType, CharsetUTF8)
+	ctx.WriteStringC(code, http.StatusText(code))
+}
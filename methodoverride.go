@@ -0,0 +1,110 @@
+package dotweb
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxMethodOverrideFormSize is the max body size read to find the form field of method, like http.Request.ParseForm
+const maxMethodOverrideFormSize = 10 << 20
+
+type (
+	// MethodOverrideConfig is the config of MethodOverrideMiddleware
+	MethodOverrideConfig struct {
+		// Header is the request header of method, default is X-HTTP-Method-Override
+		Header string
+		// FormKey is the form field of method in application/x-www-form-urlencoded body, default is _method,
+		// it is only read when the header is not set
+		FormKey string
+	}
+
+	// MethodOverrideMiddleware override the method of POST request with header or form field,
+	// e.g. for html forms and clients behind proxies which only pass POST.
+	// It must be used on app by DotWeb.Use, the method is rewritten before route lookup,
+	// the form field is read without consuming the body, json and multipart bodies are never read
+	MethodOverrideMiddleware struct {
+		BaseMiddleware
+		config MethodOverrideConfig
+	}
+)
+
+// DefaultMethodOverrideConfig return the config use X-HTTP-Method-Override header and _method form field
+func DefaultMethodOverrideConfig() MethodOverrideConfig {
+	return MethodOverrideConfig{Header: HeaderXHTTPMethodOverride, FormKey: "_method"}
+}
+
+// NewMethodOverrideMiddleware create MethodOverrideMiddleware with config, empty fields use the default
+func NewMethodOverrideMiddleware(config MethodOverrideConfig) *MethodOverrideMiddleware {
+	def := DefaultMethodOverrideConfig()
+	if config.Header == "" {
+		config.Header = def.Header
+	}
+	if config.FormKey == "" {
+		config.FormKey = def.FormKey
+	}
+	return &MethodOverrideMiddleware{config: config}
+}
+
+// MethodOverrideMiddlewareFunc return MiddlewareFunc which create MethodOverrideMiddleware with config,
+// it can be registered by DotWeb.RegisterMiddlewareFunc and used by name in config file
+func MethodOverrideMiddlewareFunc(config MethodOverrideConfig) MiddlewareFunc {
+	return func() Middleware {
+		return NewMethodOverrideMiddleware(config)
+	}
+}
+
+// Handle call next, the method is already overridden before route lookup
+func (m *MethodOverrideMiddleware) Handle(ctx Context) error {
+	return m.Next(ctx)
+}
+
+// override replace POST request's method with header or form field
+func (m *MethodOverrideMiddleware) override(req *http.Request) {
+	if req.Method != http.MethodPost {
+		return
+	}
+	method := req.Header.Get(m.config.Header)
+	if method == "" && parseMediaType(req.Header.Get(HeaderContentType)) == MIMEApplicationForm {
+		method = m.formMethod(req)
+	}
+	method = strings.ToUpper(method)
+	if method == "" || method == RouteMethod_Any || method == RouteMethod_HiJack || method == RouteMethod_WebSocket {
+		return
+	}
+	if isValidMethod(method) {
+		req.Method = method
+	}
+}
+
+// formMethod read the form field of method from urlencoded body, the body is restored for handler
+func (m *MethodOverrideMiddleware) formMethod(req *http.Request) string {
+	if req.Body == nil {
+		return ""
+	}
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxMethodOverrideFormSize))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), req.Body), req.Body}
+	if err != nil {
+		return ""
+	}
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return ""
+	}
+	return values.Get(m.config.FormKey)
+}
+
+// findMethodOverrideMiddleware return the first MethodOverrideMiddleware in app middlewares
+func findMethodOverrideMiddleware(ms []Middleware) *MethodOverrideMiddleware {
+	for _, m := range ms {
+		if mo, ok := m.(*MethodOverrideMiddleware); ok {
+			return mo
+		}
+	}
+	return nil
+}
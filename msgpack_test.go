@@ -0,0 +1,56 @@
+package dotweb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+type testMsgpackBase struct {
+	ID int64 `json:"id"`
+}
+
+type testMsgpackData struct {
+	testMsgpackBase
+	Name string `json:"name"`
+	Age  int    `msgpack:"age,omitempty"`
+	Skip string `msgpack:"-"`
+	Tags []string
+	At   time.Time
+	Next *testMsgpackData
+}
+
+func TestMsgpackMarshal(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		data  string
+	}{
+		{nil, "\xc0"},
+		{true, "\xc3"},
+		{-1, "\xff"},
+		{-33, "\xd0\xdf"},
+		{200, "\xcc\xc8"},
+		{70000, "\xce\x00\x01\x11\x70"},
+		{1.5, "\xcb\x3f\xf8\x00\x00\x00\x00\x00\x00"},
+		{[]byte{1, 2}, "\xc4\x02\x01\x02"},
+		{map[string]interface{}{"b": []interface{}{true, nil}, "a": 1}, "\x82\xa1a\x01\xa1b\x92\xc3\xc0"},
+		{time.Unix(1, 0), "\xd6\xff\x00\x00\x00\x01"},
+		{time.Unix(1, 1), "\xd7\xff\x00\x00\x00\x04\x00\x00\x00\x01"},
+		{testMsgpackData{testMsgpackBase: testMsgpackBase{ID: 7}, Name: "go", Skip: "x", At: time.Unix(1, 0)},
+			"\x85\xa2id\x07\xa4name\xa2go\xa4Tags\xc0\xa2At\xd6\xff\x00\x00\x00\x01\xa4Next\xc0"},
+	}
+	for _, c := range cases {
+		data, err := MsgpackMarshal(c.value)
+		test.Nil(t, err)
+		test.Equal(t, c.data, string(data))
+	}
+
+	// cyclic value
+	node := &testMsgpackData{}
+	node.Next = node
+	_, err := MsgpackMarshal(node)
+	test.NotNil(t, err)
+	_, err = MsgpackMarshal(make(chan int))
+	test.NotNil(t, err)
+}
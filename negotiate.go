@@ -0,0 +1,188 @@
+package dotweb
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotAcceptable is returned by Negotiate when no encoder matches request Accept,
+// DefaultHTTPErrorHandler reply it with 406
+var ErrNotAcceptable = errors.New("no acceptable response media type")
+
+type (
+	// Encoder encode response data
+	Encoder interface {
+		Encode(i interface{}) ([]byte, error)
+	}
+
+	// EncoderFunc is an adapter to allow the use of ordinary functions as Encoder
+	EncoderFunc func(i interface{}) ([]byte, error)
+
+	encoderEntry struct {
+		mediaType   string
+		contentType string
+		encoder     Encoder
+	}
+
+	// encoderRegistry keep encoders in registered order, the first one is used when Accept is empty or */*
+	encoderRegistry struct {
+		entries []*encoderEntry
+		lock    *sync.RWMutex
+	}
+
+	// acceptRange is a media range in Accept header
+	acceptRange struct {
+		typ     string
+		subtype string
+		q       float64
+		index   int
+	}
+)
+
+// Encode calls f(i)
+func (f EncoderFunc) Encode(i interface{}) ([]byte, error) {
+	return f(i)
+}
+
+func newEncoderRegistry() *encoderRegistry {
+	r := &encoderRegistry{lock: new(sync.RWMutex)}
+	r.register(MIMEApplicationJSONCharsetUTF8, EncoderFunc(json.Marshal))
+	r.register(MIMEApplicationXMLCharsetUTF8, EncoderFunc(xml.Marshal))
+	r.register(MIMEApplicationYAML, EncoderFunc(yaml.Marshal))
+	r.register(MIMETextPlainCharsetUTF8, EncoderFunc(encodeText))
+	r.register(MIMEApplicationMsgpack, EncoderFunc(MsgpackMarshal))
+	return r
+}
+
+// register add encoder, encoder with same media type is replaced in place
+func (r *encoderRegistry) register(contentType string, encoder Encoder) {
+	mediaType := parseMediaType(contentType)
+	if mediaType == "" || encoder == nil {
+		panic("encoder mime and encoder must not be empty")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry := &encoderEntry{mediaType: mediaType, contentType: contentType, encoder: encoder}
+	for i, e := range r.entries {
+		if e.mediaType == mediaType {
+			r.entries[i] = entry
+			return
+		}
+	}
+	r.entries = append(r.entries, entry)
+}
+
+// match return the encoder which is most acceptable by accept header,
+// encoders are compared by q-value, then specificity of matched range, then order in Accept, then registered order
+func (r *encoderRegistry) match(accept string) *encoderEntry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if len(r.entries) == 0 {
+		return nil
+	}
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return r.entries[0]
+	}
+	var best *encoderEntry
+	var bestRange *acceptRange
+	bestSpecificity := -1
+	for _, e := range r.entries {
+		ar, specificity := matchAccept(ranges, e.mediaType)
+		if ar == nil || ar.q <= 0 {
+			continue
+		}
+		if best == nil || ar.q > bestRange.q ||
+			(ar.q == bestRange.q && (specificity > bestSpecificity ||
+				(specificity == bestSpecificity && ar.index < bestRange.index))) {
+			best, bestRange, bestSpecificity = e, ar, specificity
+		}
+	}
+	return best
+}
+
+// matchAccept return the most specific range matching media type and its specificity
+func matchAccept(ranges []*acceptRange, mediaType string) (*acceptRange, int) {
+	typ, subtype := mediaType, ""
+	if index := strings.Index(mediaType, "/"); index >= 0 {
+		typ, subtype = mediaType[:index], mediaType[index+1:]
+	}
+	var matched *acceptRange
+	specificity := -1
+	for _, ar := range ranges {
+		s := -1
+		switch {
+		case ar.typ == typ && ar.subtype == subtype:
+			s = 2
+		case ar.typ == typ && ar.subtype == "*":
+			s = 1
+		case ar.typ == "*" && ar.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			matched, specificity = ar, s
+		}
+	}
+	return matched, specificity
+}
+
+// parseAccept parse Accept header like "text/html, application/json;q=0.9, */*;q=0.1"
+func parseAccept(accept string) []*acceptRange {
+	var ranges []*acceptRange
+	for i, item := range strings.Split(accept, ",") {
+		parts := strings.Split(item, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+		if mediaType == "" {
+			continue
+		}
+		if mediaType == "*" {
+			mediaType = "*/*"
+		}
+		index := strings.Index(mediaType, "/")
+		if index < 0 {
+			continue
+		}
+		ar := &acceptRange{typ: mediaType[:index], subtype: mediaType[index+1:], q: 1, index: i}
+		for _, param := range parts[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					ar.q = q
+				}
+			}
+		}
+		ranges = append(ranges, ar)
+	}
+	return ranges
+}
+
+// encodeText encode data as plain text
+func encodeText(i interface{}) ([]byte, error) {
+	switch v := i.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return []byte(fmt.Sprint(i)), nil
+}
+
+// addVary add field to Vary header if not exists
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values(HeaderVary) {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	h.Add(HeaderVary, field)
+}
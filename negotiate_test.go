@@ -0,0 +1,52 @@
+package dotweb
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+type testNegotiateData struct {
+	Name string `json:"name" xml:"name" yaml:"name"`
+}
+
+func (d testNegotiateData) String() string {
+	return "name=" + d.Name
+}
+
+func TestContext_Negotiate(t *testing.T) {
+	app := New()
+	app.HttpServer.RegisterEncoder("application/x-test", EncoderFunc(func(i interface{}) ([]byte, error) {
+		return []byte("test:" + i.(testNegotiateData).Name), nil
+	}))
+	app.HttpServer.GET("/data", func(ctx Context) error {
+		return ctx.Negotiate(http.StatusCreated, testNegotiateData{Name: "dotweb"})
+	})
+	prepareTestApp(app)
+
+	cases := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", MIMEApplicationJSONCharsetUTF8, `{"name":"dotweb"}`},
+		{"*/*", MIMEApplicationJSONCharsetUTF8, `{"name":"dotweb"}`},
+		{"application/xml", MIMEApplicationXMLCharsetUTF8, `<testNegotiateData><name>dotweb</name></testNegotiateData>`},
+		{"text/html, application/yaml;q=0.9, */*;q=0.1", MIMEApplicationYAML, "name: dotweb\n"},
+		{"text/*, application/json;q=0.5", MIMETextPlainCharsetUTF8, "name=dotweb"},
+		{"application/json;q=0, application/x-test", "application/x-test", "test:dotweb"},
+		{"application/msgpack", MIMEApplicationMsgpack, "\x81\xa4name\xa6dotweb"},
+	}
+	for _, c := range cases {
+		w := doTestRequest(app, "GET", "/data", http.Header{HeaderAccept: {c.accept}})
+		test.Equal(t, http.StatusCreated, w.Code)
+		test.Equal(t, c.contentType, w.Header().Get(HeaderContentType))
+		test.Equal(t, c.body, w.Body.String())
+		test.Equal(t, HeaderAccept, w.Header().Get(HeaderVary))
+	}
+
+	w := doTestRequest(app, "GET", "/data", http.Header{HeaderAccept: {"image/png, */*;q=0"}})
+	test.Equal(t, http.StatusNotAcceptable, w.Code)
+	test.Equal(t, HeaderAccept, w.Header().Get(HeaderVary))
+}
@@ -0,0 +1,267 @@
+package dotweb
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/devfeel/dotweb/cache"
+)
+
+const (
+	defaultRateLimitWindow = time.Minute
+	defaultRateLimitName   = "default"
+	rateLimitCachePrefix   = "dotweb:ratelimit:"
+	tokenBucketSweepPeriod = time.Minute
+)
+
+type (
+	// RateLimitResult is the result of RateLimitStore.Take
+	RateLimitResult struct {
+		// Allowed is true if the request is not limited
+		Allowed bool
+		// Limit is the count of requests allowed in window
+		Limit int
+		// Remaining is the count of requests still allowed
+		Remaining int
+		// Reset is the time until quota is fully restored or next window begins
+		Reset time.Duration
+		// RetryAfter is the time client should wait, only set when not allowed
+		RetryAfter time.Duration
+	}
+
+	// RateLimitStore keep the counters of RateLimitMiddleware
+	RateLimitStore interface {
+		// Take consume one request of key, limit is the count of requests allowed in window
+		Take(key string, limit int, window time.Duration) (RateLimitResult, error)
+	}
+
+	// RateLimitConfig is the config of RateLimitMiddleware
+	RateLimitConfig struct {
+		// Limit is the count of requests allowed in Window
+		Limit int
+		// Window is the time window of Limit, default is 1 minute
+		Window time.Duration
+		// KeyFunc return the key which requests are counted by, default is RateLimitByRealIP,
+		// request with empty key is not limited
+		KeyFunc func(ctx Context) string
+		// Store keep the counters, default is in-process token buckets,
+		// use NewCacheRateLimitStore to share sliding window counters between instances
+		Store RateLimitStore
+		// Name is the key prefix, middlewares which share one Store must have different names
+		Name string
+	}
+
+	// RateLimitMiddleware limit the request rate of each key, reply 429 with Retry-After when exceeded.
+	// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers are sent with every limited response,
+	// rejections are recorded in ServerStateInfo.RouteLimitData with "ratelimit" prefix
+	RateLimitMiddleware struct {
+		BaseMiddleware
+		config RateLimitConfig
+	}
+
+	// tokenBucketStore is in-process RateLimitStore, each key has a bucket holding Limit tokens at most,
+	// which is refilled Limit tokens per window
+	tokenBucketStore struct {
+		mutex     sync.Mutex
+		buckets   map[string]*tokenBucket
+		lastSweep time.Time
+	}
+
+	tokenBucket struct {
+		tokens float64
+		last   time.Time
+		window time.Duration
+	}
+
+	// cacheRateLimitStore is sliding window RateLimitStore backed by cache.Cache,
+	// the count of previous window is weighted by its overlap with sliding window
+	cacheRateLimitStore struct {
+		cache cache.Cache
+	}
+)
+
+// NewRateLimitMiddleware create RateLimitMiddleware with config, panic if Limit is not positive
+func NewRateLimitMiddleware(config RateLimitConfig) *RateLimitMiddleware {
+	if config.Limit <= 0 {
+		panic("dotweb: RateLimitConfig.Limit must be positive")
+	}
+	if config.Window <= 0 {
+		config.Window = defaultRateLimitWindow
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateLimitByRealIP
+	}
+	if config.Store == nil {
+		config.Store = NewTokenBucketStore()
+	}
+	if config.Name == "" {
+		config.Name = defaultRateLimitName
+	}
+	return &RateLimitMiddleware{config: config}
+}
+
+// RateLimitMiddlewareFunc return MiddlewareFunc which create RateLimitMiddleware with config,
+// note each created middleware has its own in-process store if config.Store is nil
+func RateLimitMiddlewareFunc(config RateLimitConfig) MiddlewareFunc {
+	return func() Middleware {
+		return NewRateLimitMiddleware(config)
+	}
+}
+
+// RateLimitByRealIP count requests by Request.RealIP
+func RateLimitByRealIP(ctx Context) string {
+	return ctx.Request().RealIP()
+}
+
+// RateLimitByHeader return KeyFunc which count requests by the value of header, e.g. X-API-Key
+func RateLimitByHeader(name string) func(ctx Context) string {
+	return func(ctx Context) string {
+		return ctx.Request().Header.Get(name)
+	}
+}
+
+// RateLimitBySession count requests by session id, session must be enabled
+func RateLimitBySession(ctx Context) string {
+	return ctx.SessionID()
+}
+
+// RateLimitByUser return KeyFunc which count requests by the user id saved in Context.Items with itemKey,
+// it is usually set by auth middleware registered before
+func RateLimitByUser(itemKey string) func(ctx Context) string {
+	return func(ctx Context) string {
+		return ctx.Items().GetString(itemKey)
+	}
+}
+
+// Handle take one request from store, reply 429 if the limit is exceeded
+func (m *RateLimitMiddleware) Handle(ctx Context) error {
+	key := m.config.KeyFunc(ctx)
+	if key == "" {
+		return m.Next(ctx)
+	}
+	result, err := m.config.Store.Take(m.config.Name+":"+key, m.config.Limit, m.config.Window)
+	if err != nil {
+		// let request pass instead of rejecting all when store is not available
+		ctx.HttpServer().Logger().Error("DotWeb:RateLimitMiddleware ["+m.config.Name+"] take error: "+err.Error(), LogTarget_HttpServer)
+		return m.Next(ctx)
+	}
+	h := ctx.Response().Header()
+	h.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+	h.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	h.Set(HeaderRateLimitReset, ceilSeconds(result.Reset))
+	if result.Allowed {
+		return m.Next(ctx)
+	}
+	route := ctx.Request().Path()
+	if node := ctx.RouterNode(); node != nil {
+		route = node.Path()
+	}
+	ctx.HttpServer().StateInfo().AddRouteLimitCount(route, RouteLimit_RateLimit, 1)
+	h.Set(HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+	h.Set(HeaderContentType, CharsetUTF8)
+	return ctx.WriteStringC(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+}
+
+// ceilSeconds format duration as seconds rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// NewTokenBucketStore create in-process RateLimitStore with token buckets,
+// idle buckets are removed after they are full
+func NewTokenBucketStore() RateLimitStore {
+	return &tokenBucketStore{buckets: make(map[string]*tokenBucket), lastSweep: time.Now()}
+}
+
+// Take consume one token from the bucket of key
+func (s *tokenBucketStore) Take(key string, limit int, window time.Duration) (RateLimitResult, error) {
+	now := time.Now()
+	// tokens refilled per nanosecond
+	rate := float64(limit) / float64(window)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sweep(now)
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &tokenBucket{tokens: float64(limit), window: window}
+		s.buckets[key] = b
+	} else {
+		b.tokens = math.Min(float64(limit), b.tokens+float64(now.Sub(b.last))*rate)
+	}
+	b.last = now
+	result := RateLimitResult{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((float64(limit) - b.tokens) / rate)
+	return result, nil
+}
+
+// sweep remove buckets which are full again, mutex must be held
+func (s *tokenBucketStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < tokenBucketSweepPeriod {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= b.window {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// NewCacheRateLimitStore create sliding window RateLimitStore with cache, e.g. cache.NewRedisCache,
+// so the limit is shared by all instances using the same cache.
+// Counters expire after two windows if cache implements cache.TTLCounter, which runtime and redis cache do,
+// caches without cache.TTLCounter keep every per-window counter forever, so they should not be used in long running server
+func NewCacheRateLimitStore(c cache.Cache) RateLimitStore {
+	return &cacheRateLimitStore{cache: c}
+}
+
+// Take count the request in current window, the rejected request is not counted
+func (s *cacheRateLimitStore) Take(key string, limit int, window time.Duration) (RateLimitResult, error) {
+	now := time.Now().UnixNano()
+	size := int64(window)
+	index := now / size
+	elapsed := float64(now%size) / float64(size)
+	key = rateLimitCachePrefix + key + ":"
+	currKey := key + strconv.FormatInt(index, 10)
+
+	var curr int64
+	var err error
+	if counter, ok := s.cache.(cache.TTLCounter); ok {
+		curr, err = counter.IncrWithTTL(currKey, int64(2*window/time.Second)+1)
+	} else {
+		curr, err = s.cache.Incr(currKey)
+	}
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	// missing counter is treated as zero
+	prev, _ := s.cache.GetInt64(key + strconv.FormatInt(index-1, 10))
+
+	count := float64(prev)*(1-elapsed) + float64(curr)
+	result := RateLimitResult{Limit: limit, Reset: time.Duration(size - now%size)}
+	if count <= float64(limit) {
+		result.Allowed = true
+		result.Remaining = limit - int(math.Ceil(count))
+		return result, nil
+	}
+	s.cache.Decr(currKey)
+	curr--
+	if curr+1 > int64(limit) || prev == 0 {
+		result.RetryAfter = result.Reset
+	} else {
+		// wait until the weight of previous window is low enough
+		need := 1 - float64(int64(limit)-curr-1)/float64(prev)
+		result.RetryAfter = time.Duration((need - elapsed) * float64(size))
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package dotweb
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/cache"
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRateLimitMiddleware_TokenBucket(t *testing.T) {
+	app := New()
+	app.HttpServer.GET("/limited", func(ctx Context) error {
+		return ctx.WriteString("ok")
+	}).Use(NewRateLimitMiddleware(RateLimitConfig{Limit: 2, Window: time.Minute}))
+	app.HttpServer.GET("/api", func(ctx Context) error {
+		return ctx.WriteString("ok")
+	}).Use(NewRateLimitMiddleware(RateLimitConfig{Limit: 1, KeyFunc: RateLimitByHeader("X-API-Key")}))
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/limited", nil)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "2", w.Header().Get(HeaderRateLimitLimit))
+	test.Equal(t, "1", w.Header().Get(HeaderRateLimitRemaining))
+	test.Equal(t, "30", w.Header().Get(HeaderRateLimitReset))
+	doTestRequest(app, "GET", "/limited", nil)
+	w = doTestRequest(app, "GET", "/limited", nil)
+	test.Equal(t, http.StatusTooManyRequests, w.Code)
+	test.Equal(t, "0", w.Header().Get(HeaderRateLimitRemaining))
+	test.Equal(t, "30", w.Header().Get(HeaderRetryAfter))
+	// other ip has its own bucket
+	w = doTestRequest(app, "GET", "/limited", http.Header{HeaderXRealIP: {"10.0.0.1"}})
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, uint64(1), app.HttpServer.StateInfo().RouteLimitData.GetUInt64(RouteLimit_RateLimit+":/limited"))
+
+	// request without key is not limited
+	for i := 0; i < 3; i++ {
+		w = doTestRequest(app, "GET", "/api", nil)
+		test.Equal(t, http.StatusOK, w.Code)
+	}
+	w = doTestRequest(app, "GET", "/api", http.Header{"X-Api-Key": {"k1"}})
+	test.Equal(t, http.StatusOK, w.Code)
+	w = doTestRequest(app, "GET", "/api", http.Header{"X-Api-Key": {"k1"}})
+	test.Equal(t, http.StatusTooManyRequests, w.Code)
+	w = doTestRequest(app, "GET", "/api", http.Header{"X-Api-Key": {"k2"}})
+	test.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimitMiddleware_CacheStore(t *testing.T) {
+	app := New()
+	store := NewCacheRateLimitStore(cache.NewRuntimeCache())
+	g := app.HttpServer.Group("/api")
+	g.Use(NewRateLimitMiddleware(RateLimitConfig{Limit: 2, Window: time.Hour, Store: store, Name: "api"}))
+	g.GET("/a", func(ctx Context) error {
+		return ctx.WriteString("a")
+	})
+	g.GET("/b", func(ctx Context) error {
+		return ctx.WriteString("b")
+	})
+	prepareTestApp(app)
+
+	// the limit is shared by routes of group
+	w := doTestRequest(app, "GET", "/api/a", nil)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "1", w.Header().Get(HeaderRateLimitRemaining))
+	w = doTestRequest(app, "GET", "/api/b", nil)
+	test.Equal(t, http.StatusOK, w.Code)
+	for i := 0; i < 2; i++ {
+		w = doTestRequest(app, "GET", "/api/b", nil)
+		test.Equal(t, http.StatusTooManyRequests, w.Code)
+		test.Equal(t, w.Header().Get(HeaderRateLimitReset), w.Header().Get(HeaderRetryAfter))
+	}
+	test.Equal(t, uint64(2), app.HttpServer.StateInfo().RouteLimitData.GetUInt64(RouteLimit_RateLimit+":/api/b"))
+
+	// rejected requests are not counted
+	result, err := store.Take("api:192.0.2.1", 3, time.Hour)
+	test.Nil(t, err)
+	test.Equal(t, true, result.Allowed)
+	test.Equal(t, 0, result.Remaining)
+}
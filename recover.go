@@ -0,0 +1,158 @@
+package dotweb
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	jsonutil "github.com/devfeel/dotweb/framework/json"
+)
+
+// error body formats of RecoverMiddleware
+const (
+	RecoverFormatJSON = "json"
+	RecoverFormatHTML = "html"
+	RecoverFormatText = "text"
+)
+
+type (
+	// PanicError is the error recovered from panic, with the stack of the panic goroutine
+	PanicError struct {
+		Value interface{}
+		Stack []byte
+	}
+
+	// RecoverConfig is the config of RecoverMiddleware
+	RecoverConfig struct {
+		// Format is the format of error body, chosen by request Accept if empty,
+		// the panic value and stack are only written in RunMode_Development
+		Format string
+		// OnPanic is called after panic recovered, it can be used to report panic elsewhere
+		OnPanic func(ctx Context, err *PanicError)
+		// Handler reply the panic instead of the default error body
+		Handler func(ctx Context, err *PanicError) error
+		// DisableErrorCount do not count the panic by ServerStateInfo.AddErrorCount
+		DisableErrorCount bool
+		// DisableLog do not write the panic and stack to error log
+		DisableLog bool
+	}
+
+	// RecoverMiddleware recover panic of following middlewares and handler, and reply 500 by config.
+	// Panic not recovered by middleware is still handled by router with DotWeb.ExceptionHandler,
+	// http.ErrAbortHandler is panicked again, so the response is aborted by net/http silently
+	RecoverMiddleware struct {
+		BaseMiddleware
+		config RecoverConfig
+	}
+
+	recoverBody struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Error   string `json:"error,omitempty"`
+		Stack   string `json:"stack,omitempty"`
+	}
+)
+
+// Error return the panic value as string
+func (e *PanicError) Error() string {
+	return fmt.Sprint(e.Value)
+}
+
+// Unwrap return the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// NewRecoverMiddleware create RecoverMiddleware with config
+func NewRecoverMiddleware(config RecoverConfig) *RecoverMiddleware {
+	return &RecoverMiddleware{config: config}
+}
+
+// RecoverMiddlewareFunc return MiddlewareFunc which create RecoverMiddleware with config,
+// it can be registered by DotWeb.RegisterMiddlewareFunc and used by name in config file
+func RecoverMiddlewareFunc(config RecoverConfig) MiddlewareFunc {
+	return func() Middleware {
+		return NewRecoverMiddleware(config)
+	}
+}
+
+// Handle call next middleware and recover its panic
+func (m *RecoverMiddleware) Handle(ctx Context) (err error) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+		if value == http.ErrAbortHandler {
+			panic(value)
+		}
+		err = m.recovered(ctx, &PanicError{Value: value, Stack: debug.Stack()})
+	}()
+	return m.Next(ctx)
+}
+
+// recovered log and count the panic, then reply by Handler or default error body
+func (m *RecoverMiddleware) recovered(ctx Context, panicErr *PanicError) error {
+	server := ctx.HttpServer()
+	if !m.config.DisableLog {
+		logPanic(ctx, "RecoverMiddleware error! => "+panicErr.Error()+" => "+string(panicErr.Stack))
+	}
+	if !m.config.DisableErrorCount {
+		countError(ctx, panicErr)
+	}
+	if m.config.OnPanic != nil {
+		m.config.OnPanic(ctx, panicErr)
+	}
+	// nothing can be written to websocket or hijacked connection
+	if ctx.IsWebSocket() || ctx.IsHijack() || ctx.Response().committed {
+		return nil
+	}
+	if m.config.Handler != nil {
+		return m.config.Handler(ctx, panicErr)
+	}
+	body := recoverBody{Code: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError)}
+	if server.DotApp.IsDevelopmentMode() {
+		body.Error = panicErr.Error()
+		body.Stack = string(panicErr.Stack)
+	}
+	switch m.format(ctx) {
+	case RecoverFormatHTML:
+		return ctx.WriteHtmlC(body.Code, errorPage(body.Message, body.Error, body.Stack))
+	case RecoverFormatText:
+		text := body.Message
+		if body.Error != "" {
+			text += "\n" + body.Error + "\n" + body.Stack
+		}
+		ctx.Response().Header().Set(HeaderContentType, CharsetUTF8)
+		return ctx.WriteStringC(body.Code, text)
+	default:
+		return ctx.WriteJsonC(body.Code, body)
+	}
+}
+
+// format return the configured format, or html if request Accept it, otherwise json
+func (m *RecoverMiddleware) format(ctx Context) string {
+	if m.config.Format != "" {
+		return m.config.Format
+	}
+	if strings.Contains(ctx.Request().Header.Get(HeaderAccept), MIMETextHTML) {
+		return RecoverFormatHTML
+	}
+	return RecoverFormatJSON
+}
+
+// logPanic write the panic message with request url and response header to error log
+func logPanic(ctx Context, errmsg string) {
+	logger := ctx.HttpServer().Logger()
+	if !logger.IsEnabledLog() {
+		return
+	}
+	logJson := LogJson{
+		RequestUrl: ctx.Request().RequestURI,
+		HttpHeader: fmt.Sprintln(ctx.Response().Header()),
+		HttpBody:   errmsg,
+	}
+	logger.Error(jsonutil.GetJsonString(logJson), LogTarget_HttpServer)
+}
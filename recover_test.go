@@ -0,0 +1,82 @@
+package dotweb
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	app := New()
+	app.Config.App.RunMode = RunMode_Development
+	var reported *PanicError
+	api := app.HttpServer.Group("/api")
+	api.Use(NewRecoverMiddleware(RecoverConfig{
+		OnPanic: func(ctx Context, err *PanicError) {
+			reported = err
+		},
+	}))
+	api.GET("/panic", func(ctx Context) error {
+		panic(errors.New("boom"))
+	})
+	web := app.HttpServer.Group("/web")
+	web.Use(NewRecoverMiddleware(RecoverConfig{Format: RecoverFormatHTML, DisableErrorCount: true}))
+	web.GET("/panic", func(ctx Context) error {
+		panic("<boom>")
+	})
+	custom := app.HttpServer.Group("/custom")
+	custom.Use(NewRecoverMiddleware(RecoverConfig{
+		Handler: func(ctx Context, err *PanicError) error {
+			return ctx.WriteStringC(http.StatusServiceUnavailable, "sorry")
+		},
+	}))
+	custom.GET("/panic", func(ctx Context) error {
+		panic("boom")
+	})
+	prepareTestApp(app)
+	state := app.HttpServer.StateInfo()
+
+	w := doTestRequest(app, "GET", "/api/panic", nil)
+	test.Equal(t, http.StatusInternalServerError, w.Code)
+	test.Equal(t, true, strings.HasPrefix(w.Body.String(), `{"code":500,"message":"Internal Server Error","error":"boom","stack":"`))
+	test.NotNil(t, reported)
+	test.Equal(t, "boom", reported.Unwrap().Error())
+	test.Equal(t, uint64(1), state.TotalErrorCount)
+
+	w = doTestRequest(app, "GET", "/web/panic", nil)
+	test.Equal(t, http.StatusInternalServerError, w.Code)
+	test.Equal(t, true, strings.Contains(w.Body.String(), "<p>&lt;boom&gt;</p><pre>"))
+	test.Equal(t, uint64(1), state.TotalErrorCount)
+
+	w = doTestRequest(app, "GET", "/custom/panic", nil)
+	test.Equal(t, http.StatusServiceUnavailable, w.Code)
+	test.Equal(t, "sorry", w.Body.String())
+
+	// no panic value and stack in production mode
+	app.Config.App.RunMode = RunMode_Production
+	w = doTestRequest(app, "GET", "/api/panic", http.Header{HeaderAccept: {"text/html"}})
+	test.Equal(t, "<html><head><title>Internal Server Error</title></head><body><h1>Internal Server Error</h1></body></html>", w.Body.String())
+}
+
+func TestRecoverMiddleware_ErrAbortHandler(t *testing.T) {
+	app := New()
+	exceptionCalled := false
+	app.SetExceptionHandle(func(ctx Context, err error) {
+		exceptionCalled = true
+	})
+	app.Use(NewRecoverMiddleware(RecoverConfig{}))
+	app.HttpServer.GET("/abort", func(ctx Context) error {
+		panic(http.ErrAbortHandler)
+	})
+	prepareTestApp(app)
+
+	defer func() {
+		test.Equal(t, http.ErrAbortHandler, recover())
+		test.Equal(t, false, exceptionCalled)
+		test.Equal(t, uint64(0), app.HttpServer.StateInfo().TotalErrorCount)
+	}()
+	doTestRequest(app, "GET", "/abort", nil)
+}
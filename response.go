@@ -24,6 +24,12 @@ type (
 		io.Writer
 		http.ResponseWriter
 	}
+
+	// responseWriter adapt Response to http.ResponseWriter for standard handlers,
+	// status and size are recorded but body is not kept
+	responseWriter struct {
+		res *Response
+	}
 )
 
 func NewResponse(w http.ResponseWriter) (r *Response) {
@@ -116,6 +122,14 @@ func (r *Response) Flush() {
 	r.writer.(http.Flusher).Flush()
 }
 
+// disableGzip restore the writer wrapped by gzip, used by streams which need raw output
+func (r *Response) disableGzip() {
+	if gw, ok := r.writer.(*gzipResponseWriter); ok {
+		r.writer = gw.ResponseWriter
+		r.header.Del(HeaderContentEncoding)
+	}
+}
+
 // Hijack implements the http.Hijacker interface to allow an HTTP handler to
 // take over the connection.
 // See https://golang.org/pkg/net/http/#Hijacker
@@ -160,9 +174,12 @@ func (w *gzipResponseWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
-// Flush do flush
+// Flush do flush, the compressed data is flushed to client too
 func (w *gzipResponseWriter) Flush() {
 	w.Writer.(*gzip.Writer).Flush()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 // Hijack do hijack
@@ -174,4 +191,33 @@ func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 // Push support http2 Push
 func (r *Response) Push(target string, opts *http.PushOptions) error {
 	return r.writer.(http.Pusher).Push(target, opts)
-}
\ No newline at end of file
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.res.Header()
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.res.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.res.committed {
+		w.res.WriteHeader(http.StatusOK)
+	}
+	n, err := w.res.writer.Write(b)
+	w.res.Size += int64(n)
+	return n, err
+}
+
+// Flush implements the http.Flusher interface
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.res.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.res.Hijack()
+}
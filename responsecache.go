@@ -0,0 +1,464 @@
+package dotweb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devfeel/dotweb/cache"
+)
+
+const (
+	defaultResponseCacheTTL  = time.Minute
+	defaultResponseCacheName = "default"
+	responseCachePrefix      = "dotweb:respcache:"
+)
+
+// X-Cache header values of ResponseCacheMiddleware
+const (
+	ResponseCacheHit   = "HIT"
+	ResponseCacheMiss  = "MISS"
+	ResponseCacheStale = "STALE"
+)
+
+type (
+	// ResponseCacheConfig is the config of ResponseCacheMiddleware
+	ResponseCacheConfig struct {
+		// Cache store the responses, e.g. cache.NewRuntimeCache() or cache.NewRedisCache(url)
+		Cache cache.Cache
+		// TTL is the time response is fresh, default is 1 minute,
+		// it is overridden by s-maxage or max-age of response Cache-Control
+		TTL time.Duration
+		// StaleWhileRevalidate is the time stale response is still served while it is refreshed in background,
+		// it is overridden by stale-while-revalidate of response Cache-Control
+		StaleWhileRevalidate time.Duration
+		// QueryParams are the query parameters in cache key, all query parameters are used if nil
+		QueryParams []string
+		// VaryHeaders are the request headers in cache key, e.g. "Authorization" to cache responses per user
+		VaryHeaders []string
+		// VaryCookies are the request cookies in cache key, e.g. the session id cookie to cache responses per session
+		VaryCookies []string
+		// StatusCodes are the cacheable status codes, default is 200
+		StatusCodes []int
+		// Tags return tags of the response after handler, responses can be purged by tag, e.g. "product:1"
+		Tags func(ctx Context) []string
+		// Name is the key prefix, middlewares which share one Cache must have different names
+		Name string
+	}
+
+	// ResponseCacheMiddleware cache status, headers and body of GET responses in cache.Cache.
+	// The key is made of method, host, path, query parameters, VaryHeaders, VaryCookies
+	// and request headers named by response Vary.
+	// Request with Cache-Control no-store bypass the cache, no-cache refresh it;
+	// response with Cache-Control no-store, no-cache, private or Set-Cookie header is not cached.
+	// Request with Authorization, which is not in VaryHeaders, bypass the cache,
+	// and its response is only cached with Cache-Control public, s-maxage or must-revalidate, see RFC 7234 3.2.
+	// Concurrent misses of the same key run handler only once
+	ResponseCacheMiddleware struct {
+		BaseMiddleware
+		config            ResponseCacheConfig
+		prefix            string
+		varyAuthorization bool
+		mutex             sync.Mutex
+		calls             map[string]chan struct{}
+	}
+
+	responseCacheEntry struct {
+		Status  int              `json:"status"`
+		Header  http.Header      `json:"header,omitempty"`
+		Body    []byte           `json:"body,omitempty"`
+		Stored  int64            `json:"stored"`
+		Expires int64            `json:"expires"`
+		Stale   int64            `json:"stale"`
+		Route   string           `json:"route"`
+		Version int64            `json:"version"`
+		Tags    map[string]int64 `json:"tags,omitempty"`
+	}
+
+	// responseCacheVary is saved with the key without Vary, to find the variant of request
+	responseCacheVary struct {
+		Vary []string `json:"vary,omitempty"`
+	}
+
+	// responseCacheRevalidateKey mark the request of background revalidation in request context
+	responseCacheRevalidateKey struct{}
+
+	// discardResponseWriter is the writer of background revalidation, the response is only kept in cache
+	discardResponseWriter struct {
+		header http.Header
+	}
+)
+
+// NewResponseCacheMiddleware create ResponseCacheMiddleware with config, panic if Cache is nil
+func NewResponseCacheMiddleware(config ResponseCacheConfig) *ResponseCacheMiddleware {
+	if config.Cache == nil {
+		panic("dotweb: ResponseCacheConfig.Cache must not be nil")
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultResponseCacheTTL
+	}
+	if len(config.StatusCodes) == 0 {
+		config.StatusCodes = []int{http.StatusOK}
+	}
+	if config.Name == "" {
+		config.Name = defaultResponseCacheName
+	}
+	m := &ResponseCacheMiddleware{
+		config: config,
+		prefix: responseCachePrefix + config.Name + ":",
+		calls:  make(map[string]chan struct{}),
+	}
+	for _, name := range config.VaryHeaders {
+		if http.CanonicalHeaderKey(name) == HeaderAuthorization {
+			m.varyAuthorization = true
+		}
+	}
+	return m
+}
+
+// ResponseCacheMiddlewareFunc return MiddlewareFunc which create ResponseCacheMiddleware with config,
+// it can be registered by DotWeb.RegisterMiddlewareFunc and used by name in config file
+func ResponseCacheMiddlewareFunc(config ResponseCacheConfig) MiddlewareFunc {
+	return func() Middleware {
+		return NewResponseCacheMiddleware(config)
+	}
+}
+
+// Handle serve response from cache, or call next and cache the response
+func (m *ResponseCacheMiddleware) Handle(ctx Context) error {
+	req := ctx.Request().Request
+	if req.Method != http.MethodGet || ctx.IsWebSocket() {
+		return m.Next(ctx)
+	}
+	reqCacheControl := parseCacheControl(req.Header.Get(HeaderCacheControl))
+	if _, exists := reqCacheControl["no-store"]; exists {
+		return m.Next(ctx)
+	}
+	key := m.key(ctx)
+	// response of Authorization may differ by user, it is not shared unless Authorization is in key
+	authorized := req.Header.Get(HeaderAuthorization) != "" && !m.varyAuthorization
+	_, noCache := reqCacheControl["no-cache"]
+	if req.Context().Value(responseCacheRevalidateKey{}) == nil && !noCache && !authorized {
+		if m.serve(ctx, key) {
+			return nil
+		}
+		done, leader := m.join(key)
+		if !leader {
+			// wait for the running handler and read its response from cache
+			select {
+			case <-done:
+			case <-ctx.Context().Done():
+				return ctx.Context().Err()
+			}
+			if m.serve(ctx, key) {
+				return nil
+			}
+			return m.Next(ctx)
+		}
+		defer m.leave(key, done)
+		ctx.Response().Header().Set(HeaderXCache, ResponseCacheMiss)
+	}
+	before := ctx.Response().Header().Clone()
+	err := m.Next(ctx)
+	if err == nil {
+		m.store(ctx, key, before, authorized)
+	}
+	return err
+}
+
+// PurgeRoute remove the cached responses of route, route is the registered path, e.g. /products/:id
+func (m *ResponseCacheMiddleware) PurgeRoute(route string) error {
+	_, err := m.config.Cache.Incr(m.prefix + "route:" + route)
+	return err
+}
+
+// PurgeTag remove the cached responses with tag
+func (m *ResponseCacheMiddleware) PurgeTag(tag string) error {
+	_, err := m.config.Cache.Incr(m.prefix + "tag:" + tag)
+	return err
+}
+
+// key return the cache key of request without Vary headers of response,
+// host is in the key as routes of different hosts may have same path
+func (m *ResponseCacheMiddleware) key(ctx Context) string {
+	req := ctx.Request().Request
+	query := req.URL.Query()
+	if m.config.QueryParams != nil {
+		selected := url.Values{}
+		for _, name := range m.config.QueryParams {
+			if values, exists := query[name]; exists {
+				selected[name] = values
+			}
+		}
+		query = selected
+	}
+	key := m.prefix + req.Method + ":" + strings.ToLower(req.Host) + req.URL.Path + "?" + query.Encode()
+	key = variantKey(req, key, m.config.VaryHeaders)
+	for _, name := range m.config.VaryCookies {
+		key += "|"
+		if c, err := req.Cookie(name); err == nil {
+			key += c.Value
+		}
+	}
+	return key
+}
+
+// variantKey append the request headers named by vary to key
+func variantKey(req *http.Request, key string, vary []string) string {
+	for _, field := range vary {
+		key += "|" + strings.Join(req.Header.Values(field), ",")
+	}
+	return key
+}
+
+// serve write the cached response if exists and not expired, stale response is served and refreshed in background
+func (m *ResponseCacheMiddleware) serve(ctx Context, key string) bool {
+	var vary responseCacheVary
+	if !m.load(key, &vary) {
+		return false
+	}
+	var entry responseCacheEntry
+	if !m.load(variantKey(ctx.Request().Request, key, vary.Vary), &entry) || !m.valid(&entry) {
+		return false
+	}
+	now := time.Now().UnixNano()
+	status := ResponseCacheHit
+	if now >= entry.Expires {
+		if now >= entry.Stale {
+			return false
+		}
+		status = ResponseCacheStale
+		m.revalidate(ctx, key)
+	}
+	h := ctx.Response().Header()
+	for k, v := range entry.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	h.Set(HeaderAge, strconv.FormatInt((now-entry.Stored)/int64(time.Second), 10))
+	h.Set(HeaderXCache, status)
+	ctx.Write(entry.Status, entry.Body)
+	return true
+}
+
+// valid check the route and tags of entry are not purged after it is stored
+func (m *ResponseCacheMiddleware) valid(entry *responseCacheEntry) bool {
+	if m.version("route:"+entry.Route) != entry.Version {
+		return false
+	}
+	for tag, version := range entry.Tags {
+		if m.version("tag:"+tag) != version {
+			return false
+		}
+	}
+	return true
+}
+
+// version return the purge count of route or tag
+func (m *ResponseCacheMiddleware) version(name string) int64 {
+	version, _ := m.config.Cache.GetInt64(m.prefix + name)
+	return version
+}
+
+// revalidate refresh the stale response in background, by serving a copy of request without cache lookup
+func (m *ResponseCacheMiddleware) revalidate(ctx Context, key string) {
+	done, leader := m.join(key)
+	if !leader {
+		// being refreshed
+		return
+	}
+	server := ctx.HttpServer()
+	req := ctx.Request().Request.Clone(context.WithValue(context.Background(), responseCacheRevalidateKey{}, true))
+	// body is cached uncompressed
+	req.Header.Del(HeaderAcceptEncoding)
+	go func() {
+		defer m.leave(key, done)
+		server.ServeHTTP(&discardResponseWriter{header: make(http.Header)}, req)
+	}()
+}
+
+// store save the response if it is cacheable, only headers set after before are saved
+func (m *ResponseCacheMiddleware) store(ctx Context, key string, before http.Header, authorized bool) {
+	res := ctx.Response()
+	if ctx.IsHijack() || !m.cacheableStatus(res.Status) || res.Size != int64(len(res.Body())) {
+		return
+	}
+	h := res.Header()
+	if h.Get(HeaderSetCookie) != "" {
+		return
+	}
+	cacheControl := parseCacheControl(h.Get(HeaderCacheControl))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, exists := cacheControl[directive]; exists {
+			return
+		}
+	}
+	if authorized && !hasCacheControl(cacheControl, "public", "s-maxage", "must-revalidate") {
+		return
+	}
+	var vary []string
+	for _, v := range h.Values(HeaderVary) {
+		for _, field := range strings.Split(v, ",") {
+			field = http.CanonicalHeaderKey(strings.TrimSpace(field))
+			if field == "*" {
+				return
+			}
+			// body is cached uncompressed, encoding is decided by each request
+			if field != "" && field != HeaderAcceptEncoding {
+				vary = append(vary, field)
+			}
+		}
+	}
+	ttl := cacheControlSeconds(cacheControl, "s-maxage", cacheControlSeconds(cacheControl, "max-age", m.config.TTL))
+	if ttl <= 0 {
+		return
+	}
+	stale := cacheControlSeconds(cacheControl, "stale-while-revalidate", m.config.StaleWhileRevalidate)
+
+	now := time.Now()
+	entry := &responseCacheEntry{
+		Status:  res.Status,
+		Header:  make(http.Header),
+		Body:    res.Body(),
+		Stored:  now.UnixNano(),
+		Expires: now.Add(ttl).UnixNano(),
+		Stale:   now.Add(ttl + stale).UnixNano(),
+		Route:   ctx.Request().URL.Path,
+	}
+	if node := ctx.RouterNode(); node != nil {
+		entry.Route = node.Path()
+	}
+	entry.Version = m.version("route:" + entry.Route)
+	if m.config.Tags != nil {
+		entry.Tags = make(map[string]int64)
+		for _, tag := range m.config.Tags(ctx) {
+			entry.Tags[tag] = m.version("tag:" + tag)
+		}
+	}
+	for k, v := range h {
+		switch k {
+		case HeaderContentEncoding, HeaderContentLength, HeaderXCache:
+			continue
+		}
+		if !equalValues(before[k], v) {
+			entry.Header[k] = v
+		}
+	}
+	seconds := int64((ttl+stale+time.Second-1)/time.Second) + 1
+	m.save(key, &responseCacheVary{Vary: vary}, seconds)
+	m.save(variantKey(ctx.Request().Request, key, vary), entry, seconds)
+}
+
+func (m *ResponseCacheMiddleware) cacheableStatus(status int) bool {
+	for _, code := range m.config.StatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// load read json value from cache, return false if not exists or invalid
+func (m *ResponseCacheMiddleware) load(key string, v interface{}) bool {
+	data, err := m.config.Cache.GetString(key)
+	if err != nil || data == "" {
+		return false
+	}
+	return json.Unmarshal([]byte(data), v) == nil
+}
+
+// save write value as json string to cache, so it works with both runtime and redis cache
+func (m *ResponseCacheMiddleware) save(key string, v interface{}, ttl int64) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	m.config.Cache.Set(key, string(data), ttl)
+}
+
+// join return the channel closed when the running handler of key finished, leader is true if none is running
+func (m *ResponseCacheMiddleware) join(key string) (done chan struct{}, leader bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if done, exists := m.calls[key]; exists {
+		return done, false
+	}
+	done = make(chan struct{})
+	m.calls[key] = done
+	return done, true
+}
+
+func (m *ResponseCacheMiddleware) leave(key string, done chan struct{}) {
+	m.mutex.Lock()
+	delete(m.calls, key)
+	m.mutex.Unlock()
+	close(done)
+}
+
+// parseCacheControl parse Cache-Control header into lower-case directives and their values
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value := part, ""
+		if index := strings.Index(part, "="); index >= 0 {
+			name, value = part[:index], strings.Trim(part[index+1:], `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return directives
+}
+
+// hasCacheControl check any of the directives exists
+func hasCacheControl(directives map[string]string, names ...string) bool {
+	for _, name := range names {
+		if _, exists := directives[name]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControlSeconds return the seconds of directive as duration, or def if not exists
+func cacheControlSeconds(directives map[string]string, name string, def time.Duration) time.Duration {
+	value, exists := directives[name]
+	if !exists {
+		return def
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(code int) {}
+
+func (w *discardResponseWriter) Flush() {}
@@ -0,0 +1,215 @@
+package dotweb
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/cache"
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestResponseCacheMiddleware(t *testing.T) {
+	app := New()
+	var count int64
+	m := NewResponseCacheMiddleware(ResponseCacheConfig{
+		Cache:       cache.NewRuntimeCache(),
+		QueryParams: []string{"id"},
+		Tags: func(ctx Context) []string {
+			return []string{"product:" + ctx.QueryString("id")}
+		},
+	})
+	app.HttpServer.GET("/products", func(ctx Context) error {
+		n := atomic.AddInt64(&count, 1)
+		ctx.Response().Header().Set("X-Count", strconv.FormatInt(n, 10))
+		return ctx.WriteString("product " + ctx.QueryString("id"))
+	}).Use(m)
+	app.HttpServer.GET("/lang", func(ctx Context) error {
+		atomic.AddInt64(&count, 1)
+		ctx.Response().Header().Set(HeaderVary, "Accept-Language")
+		return ctx.WriteString(ctx.Request().Header.Get("Accept-Language"))
+	}).Use(m)
+	app.HttpServer.GET("/private", func(ctx Context) error {
+		atomic.AddInt64(&count, 1)
+		ctx.Response().Header().Set(HeaderCacheControl, "private")
+		return ctx.WriteString("private")
+	}).Use(m)
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/products?id=1&utm=a", nil)
+	test.Equal(t, ResponseCacheMiss, w.Header().Get(HeaderXCache))
+	// query parameters not selected are ignored
+	w = doTestRequest(app, "GET", "/products?utm=b&id=1", nil)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, ResponseCacheHit, w.Header().Get(HeaderXCache))
+	test.Equal(t, "product 1", w.Body.String())
+	test.Equal(t, "1", w.Header().Get("X-Count"))
+	test.Equal(t, "0", w.Header().Get(HeaderAge))
+	doTestRequest(app, "GET", "/products?id=2", nil)
+	test.Equal(t, int64(2), atomic.LoadInt64(&count))
+
+	// request no-store bypass cache, no-cache refresh it
+	w = doTestRequest(app, "GET", "/products?id=1", http.Header{HeaderCacheControl: {"no-store"}})
+	test.Equal(t, "", w.Header().Get(HeaderXCache))
+	w = doTestRequest(app, "GET", "/products?id=1", http.Header{HeaderCacheControl: {"no-cache"}})
+	test.Equal(t, "4", w.Header().Get("X-Count"))
+	w = doTestRequest(app, "GET", "/products?id=1", nil)
+	test.Equal(t, "4", w.Header().Get("X-Count"))
+
+	// purge by tag and route
+	m.PurgeTag("product:1")
+	w = doTestRequest(app, "GET", "/products?id=1", nil)
+	test.Equal(t, "5", w.Header().Get("X-Count"))
+	w = doTestRequest(app, "GET", "/products?id=2", nil)
+	test.Equal(t, ResponseCacheHit, w.Header().Get(HeaderXCache))
+	m.PurgeRoute("/products")
+	w = doTestRequest(app, "GET", "/products?id=2", nil)
+	test.Equal(t, ResponseCacheMiss, w.Header().Get(HeaderXCache))
+
+	// keyed by Vary headers
+	count = 0
+	for _, lang := range []string{"en", "fr", "en", "fr"} {
+		w = doTestRequest(app, "GET", "/lang", http.Header{"Accept-Language": {lang}})
+		test.Equal(t, lang, w.Body.String())
+	}
+	test.Equal(t, int64(2), count)
+
+	// private response is not cached
+	doTestRequest(app, "GET", "/private", nil)
+	w = doTestRequest(app, "GET", "/private", nil)
+	test.Equal(t, ResponseCacheMiss, w.Header().Get(HeaderXCache))
+	test.Equal(t, int64(4), count)
+}
+
+func TestResponseCacheMiddleware_Host(t *testing.T) {
+	app := New()
+	m := NewResponseCacheMiddleware(ResponseCacheConfig{Cache: cache.NewRuntimeCache()})
+	app.HttpServer.Host("{tenant}.example.com").GET("/profile", func(ctx Context) error {
+		return ctx.WriteString(ctx.GetRouterName("tenant"))
+	}).Use(m)
+	prepareTestApp(app)
+
+	// responses of different hosts never share the cache
+	for _, tenant := range []string{"acme", "globex", "acme", "globex"} {
+		w := doTestRequest(app, "GET", "http://"+tenant+".example.com/profile", nil)
+		test.Equal(t, tenant, w.Body.String())
+	}
+	w := doTestRequest(app, "GET", "http://ACME.example.com/profile", nil)
+	test.Equal(t, ResponseCacheHit, w.Header().Get(HeaderXCache))
+	test.Equal(t, "acme", w.Body.String())
+}
+
+func TestResponseCacheMiddleware_Authorization(t *testing.T) {
+	app := New()
+	m := NewResponseCacheMiddleware(ResponseCacheConfig{Cache: cache.NewRuntimeCache()})
+	app.HttpServer.GET("/me", func(ctx Context) error {
+		return ctx.WriteString("user " + ctx.Request().Header.Get(HeaderAuthorization))
+	}).Use(m)
+	app.HttpServer.GET("/public", func(ctx Context) error {
+		ctx.Response().Header().Set(HeaderCacheControl, "public")
+		return ctx.WriteString("public")
+	}).Use(m)
+	session := NewResponseCacheMiddleware(ResponseCacheConfig{
+		Cache:       cache.NewRuntimeCache(),
+		VaryHeaders: []string{"authorization"},
+		VaryCookies: []string{"sid"},
+	})
+	app.HttpServer.GET("/session", func(ctx Context) error {
+		sid, _ := ctx.Request().Cookie("sid")
+		return ctx.WriteString("user " + ctx.Request().Header.Get(HeaderAuthorization) + " " + sid.Value)
+	}).Use(session)
+	prepareTestApp(app)
+
+	// response of user is never served to other users
+	for _, user := range []string{"alice", "bob"} {
+		w := doTestRequest(app, "GET", "/me", http.Header{HeaderAuthorization: {user}})
+		test.Equal(t, "user "+user, w.Body.String())
+	}
+	w := doTestRequest(app, "GET", "/me", nil)
+	test.Equal(t, ResponseCacheMiss, w.Header().Get(HeaderXCache))
+	test.Equal(t, "user ", w.Body.String())
+
+	// public response is shared
+	doTestRequest(app, "GET", "/public", http.Header{HeaderAuthorization: {"alice"}})
+	w = doTestRequest(app, "GET", "/public", nil)
+	test.Equal(t, ResponseCacheHit, w.Header().Get(HeaderXCache))
+
+	// cached per user and session
+	for i := 0; i < 2; i++ {
+		for _, user := range []string{"alice", "bob"} {
+			w = doTestRequest(app, "GET", "/session", http.Header{HeaderAuthorization: {user}, HeaderCookie: {"sid=" + user}})
+			test.Equal(t, "user "+user+" "+user, w.Body.String())
+			if i > 0 {
+				test.Equal(t, ResponseCacheHit, w.Header().Get(HeaderXCache))
+			}
+		}
+	}
+	w = doTestRequest(app, "GET", "/session", http.Header{HeaderAuthorization: {"alice"}, HeaderCookie: {"sid=other"}})
+	test.Equal(t, ResponseCacheMiss, w.Header().Get(HeaderXCache))
+	test.Equal(t, "user alice other", w.Body.String())
+}
+
+func TestResponseCacheMiddleware_Coalescing(t *testing.T) {
+	app := New()
+	var count int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+	app.HttpServer.GET("/slow", func(ctx Context) error {
+		if atomic.AddInt64(&count, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return ctx.WriteString("slow")
+	}).Use(NewResponseCacheMiddleware(ResponseCacheConfig{Cache: cache.NewRuntimeCache()}))
+	prepareTestApp(app)
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = doTestRequest(app, "GET", "/slow", nil).Body.String()
+		}(i)
+	}
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	test.Equal(t, int64(1), atomic.LoadInt64(&count))
+	for _, body := range bodies {
+		test.Equal(t, "slow", body)
+	}
+}
+
+func TestResponseCacheMiddleware_StaleWhileRevalidate(t *testing.T) {
+	app := New()
+	var count int64
+	app.HttpServer.GET("/news", func(ctx Context) error {
+		return ctx.WriteString("news " + strconv.FormatInt(atomic.AddInt64(&count, 1), 10))
+	}).Use(NewResponseCacheMiddleware(ResponseCacheConfig{
+		Cache:                cache.NewRuntimeCache(),
+		TTL:                  50 * time.Millisecond,
+		StaleWhileRevalidate: time.Minute,
+	}))
+	prepareTestApp(app)
+
+	doTestRequest(app, "GET", "/news", nil)
+	time.Sleep(60 * time.Millisecond)
+	w := doTestRequest(app, "GET", "/news", nil)
+	test.Equal(t, ResponseCacheStale, w.Header().Get(HeaderXCache))
+	test.Equal(t, "news 1", w.Body.String())
+
+	// refreshed in background
+	for i := 0; i < 20; i++ {
+		if w = doTestRequest(app, "GET", "/news", nil); w.Body.String() == "news 2" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.Equal(t, "news 2", w.Body.String())
+	test.Equal(t, ResponseCacheHit, w.Header().Get(HeaderXCache))
+}
@@ -1,20 +1,22 @@
 package dotweb
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	paths "path"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/devfeel/dotweb/core"
 	"github.com/devfeel/dotweb/framework/convert"
 	"github.com/devfeel/dotweb/framework/exception"
-	jsonutil "github.com/devfeel/dotweb/framework/json"
-	"golang.org/x/net/websocket"
 )
 
 const (
@@ -34,8 +36,17 @@ const (
 	routerExpressSplit = "^$^"
 )
 
+const (
+	// mountPathKey is the catch-all param name of mounted routes
+	mountPathKey = "mountpath"
+)
+
 var (
 	HttpMethodMap map[string]string
+
+	// anyMethods are the methods registered by Any, custom methods are appended by RegisterHttpMethod
+	anyMethods  = []string{RouteMethod_HEAD, RouteMethod_GET, RouteMethod_POST, RouteMethod_PUT, RouteMethod_DELETE, RouteMethod_PATCH, RouteMethod_OPTIONS}
+	methodMutex = new(sync.RWMutex)
 )
 
 func init() {
@@ -53,6 +64,53 @@ func init() {
 
 }
 
+// RegisterHttpMethod register custom http methods like PROPFIND, PURGE, REPORT,
+// registered methods can be used by RegisterRoute and are included in Any and Allow header.
+// It should be called before routes registered.
+func RegisterHttpMethod(methods ...string) {
+	methodMutex.Lock()
+	defer methodMutex.Unlock()
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		if !isMethodToken(method) {
+			panic("illegal http method '" + method + "'")
+		}
+		if _, exists := HttpMethodMap[method]; exists {
+			continue
+		}
+		HttpMethodMap[method] = method
+		anyMethods = append(anyMethods, method)
+	}
+}
+
+// isValidMethod check method can be registered by RegisterRoute
+func isValidMethod(method string) bool {
+	methodMutex.RLock()
+	_, exists := HttpMethodMap[method]
+	methodMutex.RUnlock()
+	return exists
+}
+
+// getAnyMethods return copy of the methods registered by Any
+func getAnyMethods() []string {
+	methodMutex.RLock()
+	defer methodMutex.RUnlock()
+	return append([]string{}, anyMethods...)
+}
+
+// isMethodToken check method is a valid http token
+func isMethodToken(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range method {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && !strings.ContainsRune("!#$%&'*+-.^_`|~", c) {
+			return false
+		}
+	}
+	return true
+}
+
 type (
 	// Router is the interface that wraps the router method.
 	Router interface {
@@ -67,14 +125,18 @@ type (
 		PATCH(path string, handle HttpHandle) RouterNode
 		DELETE(path string, handle HttpHandle) RouterNode
 		HiJack(path string, handle HttpHandle)
-		WebSocket(path string, handle HttpHandle)
+		WebSocket(path string, handle HttpHandle) RouterNode
 		Any(path string, handle HttpHandle)
+		Mount(prefix string, handler http.Handler)
 		RegisterHandlerFunc(routeMethod string, path string, handler http.HandlerFunc) RouterNode
 		RegisterRoute(routeMethod string, path string, handle HttpHandle) RouterNode
 		RegisterHandler(name string, handler HttpHandle)
 		GetHandler(name string) (HttpHandle, bool)
 		MatchPath(ctx Context, routePath string) bool
 		GetAllRouterExpress() map[string]struct{}
+		Routes() []RouteInfo
+		Remove(routeMethod string, path string) error
+		URL(name string, params ...interface{}) (string, error)
 	}
 
 	RouterNode interface {
@@ -84,6 +146,33 @@ type (
 		Middlewares() []Middleware
 		Path() string
 		Node() *Node
+		Name(name string) *Node
+		RouteName() string
+		MaxBody(size int64) *Node
+		Timeout(timeout time.Duration) *Node
+		MaxConcurrent(max int) *Node
+		SetMethodNotAllowedHandle(handler StandardHandle) *Node
+		SetOptionsHandle(handler StandardHandle) *Node
+	}
+
+	// RouteInfo describe a registered route
+	RouteInfo struct {
+		Method           string
+		Path             string
+		Name             string
+		Handler          string
+		Host             string
+		Group            string
+		AppMiddlewares   []string
+		GroupMiddlewares []string
+		Middlewares      []string
+		Version          string
+		IsStatic         bool
+		IsHijack         bool
+		IsWebSocket      bool
+		MaxBody          int64
+		Timeout          time.Duration
+		MaxConcurrent    int
 	}
 
 	ValueNode struct {
@@ -95,11 +184,18 @@ type (
 	// router is a http.Handler which can be used to dispatch requests to different
 	// handler functions via configurable routes
 	router struct {
-		Nodes            map[string]*Node
+		nodes            atomic.Value // map[string]*Node, replaced by copy-on-write
+		nodesMutex       *sync.RWMutex
 		allRouterExpress map[string]struct{}
 		server           *HttpServer
 		handlerMap       map[string]HttpHandle
 		handlerMutex     *sync.RWMutex
+		namedRoutes      map[string]*namedRoute
+		namedMutex       *sync.RWMutex
+		host             string
+		// published is set before the first request is served by router,
+		// so routes are changed copy-on-write even if server is not started by DotWeb, e.g. httptest.NewServer
+		published atomic.Bool
 
 		// Enables automatic redirection if the current route can't be matched but a
 		// handler for the path with (without) the trailing slash exists.
@@ -139,6 +235,30 @@ type (
 	// The slice is ordered, the first URL parameter is also the first slice value.
 	// It is therefore safe to read values by the index.
 	Params []Param
+
+	// namedRoute is a route registered with RouterNode.Name, used to build url
+	// routeOption is the route info set on node when route is added
+	routeOption struct {
+		handlerName string
+		isStatic    bool
+		isHijack    bool
+		isWebSocket bool
+		isAuto      bool
+		group       *xGroup
+	}
+
+	namedRoute struct {
+		pattern string
+		parts   []routePart
+	}
+
+	// routePart is a static part or a wildcard of the route pattern
+	routePart struct {
+		static     string
+		param      string
+		catchAll   bool
+		constraint *paramConstraint
+	}
 )
 
 // ByName returns the value of the first Param which key matches the given name.
@@ -167,10 +287,13 @@ func NewRouter(server *HttpServer) *router {
 		RedirectTrailingSlash: redirectTrailingSlash,
 		RedirectFixedPath:     true,
 		HandleOPTIONS:         true,
+		nodesMutex:            new(sync.RWMutex),
 		allRouterExpress:      make(map[string]struct{}),
 		server:                server,
 		handlerMap:            make(map[string]HttpHandle),
 		handlerMutex:          new(sync.RWMutex),
+		namedRoutes:           make(map[string]*namedRoute),
+		namedMutex:            new(sync.RWMutex),
 	}
 }
 
@@ -187,13 +310,204 @@ func (r *router) GetHandler(name string) (HttpHandle, bool) {
 	return v, exists
 }
 
-// GetAllRouterExpress return router.allRouterExpress
+// GetAllRouterExpress return copy of router.allRouterExpress
 func (r *router) GetAllRouterExpress() map[string]struct{} {
-	return r.allRouterExpress
+	r.nodesMutex.RLock()
+	defer r.nodesMutex.RUnlock()
+	expresses := make(map[string]struct{}, len(r.allRouterExpress))
+	for k := range r.allRouterExpress {
+		expresses[k] = struct{}{}
+	}
+	return expresses
+}
+
+// Routes return all routes registered on the router, sorted by path and method
+func (r *router) Routes() []RouteInfo {
+	var routes []RouteInfo
+	for method, root := range r.loadNodes() {
+		root.walk(func(n *Node) {
+			if n.handle == nil {
+				return
+			}
+			if !n.isVersionHolder {
+				routes = append(routes, r.routeInfo(method, n))
+			}
+			for _, v := range n.variants {
+				routes = append(routes, r.routeInfo(method, v))
+			}
+		})
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Version < routes[j].Version
+	})
+	return routes
+}
+
+// routeInfo create RouteInfo from the node which has handle
+func (r *router) routeInfo(method string, n *Node) RouteInfo {
+	info := RouteInfo{
+		Method:           method,
+		Path:             n.fullPath,
+		Name:             n.name,
+		Handler:          n.handlerName,
+		Host:             r.host,
+		AppMiddlewares:   middlewareNames(n.appMiddlewares, n.fullPath),
+		GroupMiddlewares: middlewareNames(n.groupMiddlewares, n.fullPath),
+		Middlewares:      middlewareNames(n.middlewares, n.fullPath),
+		Version:          n.version,
+		IsStatic:         n.isStatic,
+		IsHijack:         n.isHijack,
+		IsWebSocket:      n.isWebSocket,
+	}
+	if n.isWebSocket {
+		info.Method = RouteMethod_WebSocket
+	}
+	if n.group != nil {
+		info.Group = n.group.prefix
+	}
+	if n.limit != nil {
+		info.MaxBody = n.limit.maxBody
+		info.Timeout = n.limit.timeout
+		info.MaxConcurrent = int(n.limit.maxConcurrent)
+	}
+	return info
+}
+
+// middlewareNames return type names of middlewares which will run on the route path
+func middlewareNames(ms []Middleware, path string) []string {
+	var names []string
+	for _, m := range ms {
+		if _, isInner := m.(*xMiddleware); isInner {
+			continue
+		}
+		if m.HasExclude() && m.ExistsExcludeRouter(path) {
+			continue
+		}
+		names = append(names, reflect.TypeOf(m).String())
+	}
+	return names
+}
+
+// URL build the url of the route registered with the given name,
+// params fill the route's wildcards in order and are escaped
+// simple demo:router.URL("user.show", 1) => /user/1
+func (r *router) URL(name string, params ...interface{}) (string, error) {
+	values := make([]string, len(params))
+	for i, p := range params {
+		values[i] = fmt.Sprint(p)
+	}
+	return r.buildURL(name, func(index int, key string) (string, bool) {
+		if index < len(values) {
+			return values[index], true
+		}
+		return "", false
+	})
+}
+
+// registerName register route name with full route path
+func (r *router) registerName(name, path string) {
+	r.namedMutex.Lock()
+	defer r.namedMutex.Unlock()
+	if exists, ok := r.namedRoutes[name]; ok && exists.pattern != path {
+		panic("route name '" + name + "' is already registered for path '" + exists.pattern + "'")
+	}
+	r.namedRoutes[name] = &namedRoute{pattern: path, parts: parseRoutePattern(path)}
+}
+
+// removeNames remove route names registered for path
+func (r *router) removeNames(path string) {
+	r.namedMutex.Lock()
+	defer r.namedMutex.Unlock()
+	for name, route := range r.namedRoutes {
+		if route.pattern == path {
+			delete(r.namedRoutes, name)
+		}
+	}
+}
+
+// buildURL build the url of named route, value returns the value of the
+// wildcard with the given index and key
+func (r *router) buildURL(name string, value func(index int, key string) (string, bool)) (string, error) {
+	r.namedMutex.RLock()
+	route, exists := r.namedRoutes[name]
+	r.namedMutex.RUnlock()
+	if !exists {
+		return "", errors.New("route name '" + name + "' not registered")
+	}
+
+	var buf strings.Builder
+	index := 0
+	for _, part := range route.parts {
+		if part.param == "" {
+			buf.WriteString(part.static)
+			continue
+		}
+		v, ok := value(index, part.param)
+		index++
+		if !ok {
+			return "", errors.New("missing param '" + part.param + "' for route '" + name + "'")
+		}
+		if part.catchAll {
+			// keep the '/' between segments of catch-all value
+			segments := strings.Split(strings.TrimPrefix(v, "/"), "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			buf.WriteString("/" + strings.Join(segments, "/"))
+			continue
+		}
+		if part.constraint != nil && !part.constraint.match(v) {
+			return "", errors.New("param '" + part.param + "' value '" + v + "' does not match constraint '" + part.constraint.expr + "' for route '" + name + "'")
+		}
+		buf.WriteString(url.PathEscape(v))
+	}
+	return buf.String(), nil
+}
+
+// parseRoutePattern split route pattern into static parts and wildcards
+func parseRoutePattern(pattern string) []routePart {
+	var parts []routePart
+	offset := 0
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		if c != ':' && c != '*' {
+			i++
+			continue
+		}
+		wildcard := wildcardSegment(pattern[i:])
+		staticEnd := i
+		part := routePart{param: wildcard[1:]}
+		if c == '*' {
+			// the catch-all value holds the leading '/'
+			part.catchAll = true
+			staticEnd--
+		} else {
+			if k := strings.IndexByte(part.param, '<'); k >= 0 {
+				part.param = part.param[:k]
+			}
+			part.constraint = newParamConstraint(wildcard, pattern)
+		}
+		if staticEnd > offset {
+			parts = append(parts, routePart{static: pattern[offset:staticEnd]})
+		}
+		parts = append(parts, part)
+		i += len(wildcard)
+		offset = i
+	}
+	if offset < len(pattern) {
+		parts = append(parts, routePart{static: pattern[offset:]})
+	}
+	return parts
 }
 
 func (r *router) MatchPath(ctx Context, routePath string) bool {
-	if root := r.Nodes[ctx.Request().Method]; root != nil {
+	if root := r.loadNodes()[ctx.Request().Method]; root != nil {
 		n := root.getNode(routePath)
 		return n == ctx.RouterNode().Node()
 	}
@@ -201,7 +515,7 @@ func (r *router) MatchPath(ctx Context, routePath string) bool {
 }
 
 func (r *router) getNode(httpMethod string, routePath string) *Node {
-	if root := r.Nodes[httpMethod]; root != nil {
+	if root := r.loadNodes()[httpMethod]; root != nil {
 		n := root.getNode(routePath)
 		return n
 	}
@@ -213,11 +527,37 @@ func (r *router) ServeHTTP(ctx Context) {
 	req := ctx.Request().Request
 	w := ctx.Response().Writer()
 	path := req.URL.Path
-	if root := r.Nodes[req.Method]; root != nil {
+	if !r.published.Load() {
+		r.publish()
+	}
+	if m := findMethodOverrideMiddleware(r.server.DotApp.Middlewares); m != nil {
+		m.override(req)
+	}
+	if root := r.loadNodes()[req.Method]; root != nil {
 		if handle, ps, node, tsr := root.getValue(path); handle != nil {
+			// pick the version variant of route by request
+			if len(node.variants) > 0 {
+				if node = node.selectVariant(ctx); node == nil {
+					r.handleNotFound(ctx, path)
+					return
+				}
+				handle = node.handle
+			}
+			// auto added OPTIONS route does not have the middlewares of route, answer preflight by CorsMiddleware
+			if node.isAuto && req.Method == http.MethodOptions && r.serveCorsPreflight(ctx, path) {
+				return
+			}
+			// keep params captured from request host
+			if hostParams := ctx.RouterParams(); len(hostParams) > 0 {
+				ps = append(hostParams, ps...)
+			}
 			ctx.setRouterParams(ps)
 			ctx.setRouterNode(node)
-			handle(ctx)
+			if node.limit != nil {
+				r.serveLimited(ctx, node, handle)
+			} else {
+				handle(ctx)
+			}
 			return
 		} else if req.Method != "CONNECT" && path != "/" {
 			code := 301 // Permanent redirect, request with GET method
@@ -254,10 +594,19 @@ func (r *router) ServeHTTP(ctx Context) {
 	}
 
 	if req.Method == "OPTIONS" {
+		// cors preflight is answered by CorsMiddleware before 404 and 405
+		if r.serveCorsPreflight(ctx, path) {
+			return
+		}
 		// Handle OPTIONS requests
 		if r.HandleOPTIONS {
 			if allow := r.allowed(path, req.Method); len(allow) > 0 {
 				w.Header().Set("Allow", allow)
+				if h := r.routeHandler(path, func(n *Node) StandardHandle { return n.optionsHandler }); h != nil {
+					h(ctx)
+				} else if g := r.matchGroup(path, func(g *xGroup) bool { return g.optionsHandler != nil }); g != nil {
+					g.optionsHandler(ctx)
+				}
 				return
 			}
 		}
@@ -265,20 +614,29 @@ func (r *router) ServeHTTP(ctx Context) {
 		// Handle 405
 		if allow := r.allowed(path, req.Method); len(allow) > 0 {
 			w.Header().Set("Allow", allow)
+			if h := r.routeHandler(path, func(n *Node) StandardHandle { return n.methodNotAllowedHandler }); h != nil {
+				h(ctx)
+				return
+			}
+			if g := r.matchGroup(path, func(g *xGroup) bool { return g.methodNotAllowedHandler != nil }); g != nil {
+				g.methodNotAllowedHandler(ctx)
+				return
+			}
 			// In DefaultMethodNotAllowedHandler will be call SetStatusCode(http.StatusMethodNotAllowed)
 			r.server.DotApp.MethodNotAllowedHandler(ctx)
 			return
 		}
 	}
 
-	// Handle 404
+	r.handleNotFound(ctx, path)
+}
+
+// handleNotFound handle 404
+func (r *router) handleNotFound(ctx Context, path string) {
 	// Check if request path matches any group prefix and use group's NotFoundHandler
-	// Use exact prefix match or prefix + "/" to avoid false positives (e.g., /apiv2 matching /api)
-	for _, g := range r.server.groups {
-		if (path == g.prefix || strings.HasPrefix(path, g.prefix+"/")) && g.notFoundHandler != nil {
-			g.notFoundHandler(ctx)
-			return
-		}
+	if g := r.matchGroup(path, func(g *xGroup) bool { return g.notFoundHandler != nil }); g != nil {
+		g.notFoundHandler(ctx)
+		return
 	}
 	// Fall back to app-level NotFoundHandler
 	if r.server.DotApp.NotFoundHandler != nil {
@@ -286,6 +644,43 @@ func (r *router) ServeHTTP(ctx Context) {
 	}
 }
 
+// matchGroup return the group which prefix matches path and has the handler checked by has
+// Use exact prefix match or prefix + "/" to avoid false positives (e.g., /apiv2 matching /api)
+// Only groups registered on this router are checked, the longest matched prefix wins
+func (r *router) matchGroup(path string, has func(g *xGroup) bool) *xGroup {
+	var matched *xGroup
+	for _, g := range r.server.groups {
+		if g.router != r || !has(g) {
+			continue
+		}
+		if path == g.prefix || strings.HasPrefix(path, g.prefix+"/") {
+			if matched == nil || len(g.prefix) > len(matched.prefix) {
+				matched = g
+			}
+		}
+	}
+	return matched
+}
+
+// routeHandler return the handler got from the routes of path in any method, or of their version variants
+func (r *router) routeHandler(path string, get func(n *Node) StandardHandle) StandardHandle {
+	for _, root := range r.loadNodes() {
+		handle, _, node, _ := root.getValue(path)
+		if handle == nil {
+			continue
+		}
+		if h := get(node); h != nil {
+			return h
+		}
+		for _, v := range node.variants {
+			if h := get(v); h != nil {
+				return h
+			}
+		}
+	}
+	return nil
+}
+
 // GET is a shortcut for router.Handle("GET", path, handle)
 func (r *router) GET(path string, handle HttpHandle) RouterNode {
 	return r.RegisterRoute(RouteMethod_GET, path, handle)
@@ -331,8 +726,32 @@ func (r *router) HiJack(path string, handle HttpHandle) {
 	r.RegisterRoute(RouteMethod_HiJack, path, handle)
 }
 
-func (r *router) WebSocket(path string, handle HttpHandle) {
-	r.RegisterRoute(RouteMethod_WebSocket, path, handle)
+func (r *router) WebSocket(path string, handle HttpHandle) RouterNode {
+	return r.RegisterRoute(RouteMethod_WebSocket, path, handle)
+}
+
+// Mount forward all requests below prefix to handler with the prefix stripped
+// simple demo:router.Mount("/legacy", legacyMux)
+func (r *router) Mount(prefix string, handler http.Handler) {
+	r.mount(prefix, handler, nil)
+}
+
+// mount register mounted routes which belongs to group g, g can be nil, return the registered paths
+func (r *router) mount(prefix string, handler http.Handler, g *xGroup) []string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if strings.ContainsAny(prefix, ":*") {
+		panic("mount prefix can not contain wildcard in path '" + prefix + "'")
+	}
+	handle := transferMountHandler(r.server.VirtualPath()+prefix, handler)
+	paths := []string{prefix + "/*" + mountPathKey}
+	if prefix != "" {
+		paths = append([]string{prefix}, paths...)
+	}
+	for _, path := range paths {
+		r.registerRoute(RouteMethod_Any, path, handle, g)
+	}
+	r.server.Logger().Debug("DotWeb:Router:Mount success ["+prefix+"] ["+reflect.TypeOf(handler).String()+"]", LogTarget_HttpServer)
+	return paths
 }
 
 // RegisterHandlerFunc register router with http.HandlerFunc
@@ -342,36 +761,38 @@ func (r *router) RegisterHandlerFunc(routeMethod string, path string, handler ht
 
 // RegisterRoute register router
 // support GET\POST\DELETE\PUT\HEAD\PATCH\OPTIONS\HiJack\WebSocket\ANY
+// it can also be called when server is serving, the new route will take effect immediately
 func (r *router) RegisterRoute(routeMethod string, path string, handle HttpHandle) RouterNode {
+	return r.registerRoute(routeMethod, path, handle, nil)
+}
+
+// registerRoute register router which belongs to group g, g can be nil
+func (r *router) registerRoute(routeMethod string, path string, handle HttpHandle, g *xGroup) RouterNode {
 	realPath := r.server.VirtualPath() + path
 	var node *Node
 	handleName := handlerName(handle)
 	routeMethod = strings.ToUpper(routeMethod)
-	if _, exists := HttpMethodMap[routeMethod]; !exists {
+	if !isValidMethod(routeMethod) {
 		r.server.Logger().Warn("DotWeb:Router:RegisterRoute failed [illegal method] ["+routeMethod+"] ["+realPath+"] ["+handleName+"]", LogTarget_HttpServer)
 		return nil
 	}
+	info := routeOption{handlerName: handleName, group: g}
 
-	// websocket mode,use default httpserver
+	// websocket mode,use get and upgrade after middlewares
 	if routeMethod == RouteMethod_WebSocket {
-		http.Handle(realPath, websocket.Handler(r.wrapWebSocketHandle(handle)))
+		node = r.add(RouteMethod_GET, realPath, r.wrapRouterHandle(r.wrapWebSocketHandle(handle), false), info.webSocket())
 	} else {
 		// hijack mode,use get and isHijack = true
 		if routeMethod == RouteMethod_HiJack {
-			r.add(RouteMethod_GET, realPath, r.wrapRouterHandle(handle, true))
+			r.add(RouteMethod_GET, realPath, r.wrapRouterHandle(handle, true), info.hijack())
 		} else if routeMethod == RouteMethod_Any {
-			// All GET\POST\DELETE\PUT\HEAD\PATCH\OPTIONS mode
-			r.add(RouteMethod_HEAD, realPath, r.wrapRouterHandle(handle, false))
-			r.add(RouteMethod_GET, realPath, r.wrapRouterHandle(handle, false))
-			r.add(RouteMethod_POST, realPath, r.wrapRouterHandle(handle, false))
-			r.add(RouteMethod_PUT, realPath, r.wrapRouterHandle(handle, false))
-			r.add(RouteMethod_DELETE, realPath, r.wrapRouterHandle(handle, false))
-			r.add(RouteMethod_PATCH, realPath, r.wrapRouterHandle(handle, false))
-			r.add(RouteMethod_OPTIONS, realPath, r.wrapRouterHandle(handle, false))
+			// All GET\POST\DELETE\PUT\HEAD\PATCH\OPTIONS and custom methods mode
+			for _, method := range getAnyMethods() {
+				r.add(method, realPath, r.wrapRouterHandle(handle, false), info)
+			}
 		} else {
 			// Single GET\POST\DELETE\PUT\HEAD\PATCH\OPTIONS mode
-			r.add(routeMethod, realPath, r.wrapRouterHandle(handle, false))
-			node = r.getNode(routeMethod, realPath)
+			node = r.add(routeMethod, realPath, r.wrapRouterHandle(handle, false), info)
 		}
 	}
 	r.server.Logger().Debug("DotWeb:Router:RegisterRoute success ["+routeMethod+"] ["+realPath+"] ["+handleName+"]", LogTarget_HttpServer)
@@ -382,10 +803,10 @@ func (r *router) RegisterRoute(routeMethod string, path string, handle HttpHandl
 		if routeMethod == RouteMethod_WebSocket {
 			// Nothing to do
 		} else if routeMethod == RouteMethod_HiJack {
-			r.add(RouteMethod_HEAD, realPath, r.wrapRouterHandle(handle, true))
+			r.add(RouteMethod_HEAD, realPath, r.wrapRouterHandle(handle, true), info.hijack().auto())
 			r.server.Logger().Debug("DotWeb:Router:RegisterRoute AutoHead success ["+RouteMethod_HEAD+"] ["+realPath+"] ["+handleName+"]", LogTarget_HttpServer)
-		} else if !r.existsRouter(RouteMethod_HEAD, realPath) {
-			r.add(RouteMethod_HEAD, realPath, r.wrapRouterHandle(handle, false))
+		} else if r.needAutoRoute(RouteMethod_HEAD, realPath, g) {
+			r.add(RouteMethod_HEAD, realPath, r.wrapRouterHandle(handle, false), info.auto())
 			r.server.Logger().Debug("DotWeb:Router:RegisterRoute AutoHead success ["+RouteMethod_HEAD+"] ["+realPath+"] ["+handleName+"]", LogTarget_HttpServer)
 		}
 	}
@@ -393,13 +814,14 @@ func (r *router) RegisterRoute(routeMethod string, path string, handle HttpHandl
 	// if set auto-options, add options router
 	// only enabled in hijack\GET\POST\DELETE\PUT\HEAD\PATCH\OPTIONS
 	if r.server.ServerConfig().EnabledAutoOPTIONS {
+		optionsInfo := routeOption{handlerName: handlerName(DefaultAutoOPTIONSHandler), group: g, isAuto: true}
 		if routeMethod == RouteMethod_WebSocket {
 			// Nothing to do
 		} else if routeMethod == RouteMethod_HiJack {
-			r.add(RouteMethod_OPTIONS, realPath, r.wrapRouterHandle(DefaultAutoOPTIONSHandler, true))
+			r.add(RouteMethod_OPTIONS, realPath, r.wrapRouterHandle(DefaultAutoOPTIONSHandler, true), optionsInfo.hijack())
 			r.server.Logger().Debug("DotWeb:Router:RegisterRoute AutoOPTIONS success ["+RouteMethod_OPTIONS+"] ["+realPath+"] ["+handleName+"]", LogTarget_HttpServer)
-		} else if !r.existsRouter(RouteMethod_OPTIONS, realPath) {
-			r.add(RouteMethod_OPTIONS, realPath, r.wrapRouterHandle(DefaultAutoOPTIONSHandler, false))
+		} else if r.needAutoRoute(RouteMethod_OPTIONS, realPath, g) {
+			r.add(RouteMethod_OPTIONS, realPath, r.wrapRouterHandle(DefaultAutoOPTIONSHandler, false), optionsInfo)
 			r.server.Logger().Debug("DotWeb:Router:RegisterRoute AutoOPTIONS success ["+RouteMethod_OPTIONS+"] ["+realPath+"] ["+handleName+"]", LogTarget_HttpServer)
 		}
 	}
@@ -418,6 +840,11 @@ func (r *router) ServerFile(path string, fileRoot string) RouterNode {
 // simple demo:server.RegisterServerFile(RouteMethod_GET, "/src/*", "/var/www", nil)
 // simple demo:server.RegisterServerFile(RouteMethod_GET, "/src/*filepath", "/var/www", []string{".zip", ".rar"})
 func (r *router) RegisterServerFile(routeMethod string, path string, fileRoot string, excludeExtension []string) RouterNode {
+	return r.registerServerFile(routeMethod, path, fileRoot, excludeExtension, nil)
+}
+
+// registerServerFile register ServerFile router which belongs to group g, g can be nil
+func (r *router) registerServerFile(routeMethod string, path string, fileRoot string, excludeExtension []string, g *xGroup) RouterNode {
 	realPath := r.server.VirtualPath() + path
 	node := &Node{}
 	if len(realPath) < 2 {
@@ -435,17 +862,18 @@ func (r *router) RegisterServerFile(routeMethod string, path string, fileRoot st
 		root = &core.HideReaddirFS{FileSystem: root}
 	}
 	fileServer := http.FileServer(root)
-	r.add(routeMethod, realPath, r.wrapFileHandle(fileServer, excludeExtension))
-	node = r.getNode(routeMethod, realPath)
+	info := routeOption{handlerName: "http.FileServer(" + fileRoot + ")", isStatic: true, group: g}
+	node = r.add(routeMethod, realPath, r.wrapFileHandle(fileServer, excludeExtension), info)
 
 	if r.server.ServerConfig().EnabledAutoHEAD {
 		if !r.existsRouter(RouteMethod_HEAD, realPath) {
-			r.add(RouteMethod_HEAD, realPath, r.wrapFileHandle(fileServer, excludeExtension))
+			r.add(RouteMethod_HEAD, realPath, r.wrapFileHandle(fileServer, excludeExtension), info.auto())
 		}
 	}
 	if r.server.ServerConfig().EnabledAutoOPTIONS {
 		if !r.existsRouter(RouteMethod_OPTIONS, realPath) {
-			r.add(RouteMethod_OPTIONS, realPath, r.wrapRouterHandle(DefaultAutoOPTIONSHandler, false))
+			optionsInfo := routeOption{handlerName: handlerName(DefaultAutoOPTIONSHandler), group: g, isAuto: true}
+			r.add(RouteMethod_OPTIONS, realPath, r.wrapRouterHandle(DefaultAutoOPTIONSHandler, false), optionsInfo)
 		}
 	}
 	return node
@@ -459,6 +887,24 @@ func handlerName(h HttpHandle) string {
 	return t.String()
 }
 
+// hijack return copy of the option with isHijack = true
+func (o routeOption) hijack() routeOption {
+	o.isHijack = true
+	return o
+}
+
+// webSocket return copy of the option with isWebSocket = true
+func (o routeOption) webSocket() routeOption {
+	o.isWebSocket = true
+	return o
+}
+
+// auto return copy of the option with isAuto = true
+func (o routeOption) auto() routeOption {
+	o.isAuto = true
+	return o
+}
+
 // Handle registers a new request handle with the given path and method.
 //
 // For GET, POST, PUT, PATCH and DELETE requests the respective shortcut
@@ -467,30 +913,240 @@ func handlerName(h HttpHandle) string {
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
-func (r *router) add(method, path string, handle RouterHandle, m ...Middleware) (outnode *Node) {
+//
+// Route info in opt is set on the new node before it can be matched,
+// when server is serving, the route tree is copied and swapped after change,
+// so in-flight lookups never see a half-built tree.
+// The returned node is a detached copy of the route, changes made by its methods
+// are applied to the route in current tree, even after the tree is rebuilt.
+func (r *router) add(method, path string, handle RouterHandle, opt routeOption) (outnode *Node) {
 	if path[0] != '/' {
 		panic("path must begin with '/' in path '" + path + "'")
 	}
 
-	if r.Nodes == nil {
-		r.Nodes = make(map[string]*Node)
-	}
+	r.nodesMutex.Lock()
+	defer r.nodesMutex.Unlock()
 
-	root := r.Nodes[method]
-	if root == nil {
+	nodes := r.loadNodes()
+	root := nodes[method]
+	serving := r.isServing()
+	if serving {
+		root = root.rebuild(nil)
+	} else if root == nil {
 		root = new(Node)
-		r.Nodes[method] = root
 	}
 	// fmt.Println("Handle => ", method, " - ", *root, " - ", path)
-	outnode = root.addRoute(path, handle, m...)
+	outnode = root.getNode(path)
+	if outnode == nil || outnode.handle == nil || outnode.fullPath != path {
+		outnode = root.addRoute(path, handle)
+		if opt.group != nil && opt.group.version != "" {
+			// the tree node only hold the variants of path
+			outnode.isVersionHolder = true
+			outnode.fullPath = path
+			outnode.method = method
+			outnode.router = r
+		}
+	} else if !outnode.isVersionHolder && (opt.group == nil || opt.group.version == "") {
+		panic("a handle is already registered for path '" + path + "'")
+	}
+	if opt.group != nil && opt.group.version != "" {
+		outnode = outnode.addVariant(path, handle, opt.group.version)
+	} else {
+		// replace the holder with default route
+		outnode.isVersionHolder = false
+		outnode.handle = handle
+	}
 	outnode.fullPath = path
-	r.allRouterExpress[method+routerExpressSplit+path] = struct{}{}
-	return
+	outnode.method = method
+	outnode.router = r
+	outnode.handlerName = opt.handlerName
+	outnode.isStatic = opt.isStatic
+	outnode.isHijack = opt.isHijack
+	outnode.isWebSocket = opt.isWebSocket
+	outnode.isAuto = opt.isAuto
+	if opt.group != nil {
+		outnode.group = opt.group
+		outnode.groupMiddlewares = opt.group.middlewares
+		for _, m := range outnode.groupMiddlewares {
+			if m.HasExclude() && m.ExistsExcludeRouter(path) {
+				outnode.hasExcludeMiddleware = true
+			}
+		}
+	}
+	express := method + routerExpressSplit + path
+	// app middlewares are bound on start, routes added after that bind them here
+	if r.server.serving.Load() {
+		r.server.DotApp.bindNodeAppMiddleware(express, outnode)
+	}
+
+	if nodes[method] != root {
+		r.storeNodes(nodes, method, root)
+	}
+	r.allRouterExpress[express] = struct{}{}
+	return outnode.detach()
+}
+
+// Remove remove the route registered with method and path, it is safe to call when server is serving.
+// ANY remove all methods registered by Any, HIJACK and WEBSOCKET remove the GET route,
+// the auto added HEAD and OPTIONS routes are removed when no other route left on the path.
+func (r *router) Remove(routeMethod string, path string) error {
+	realPath := r.server.VirtualPath() + path
+	routeMethod = strings.ToUpper(routeMethod)
+	var methods []string
+	switch routeMethod {
+	case RouteMethod_Any:
+		methods = getAnyMethods()
+	case RouteMethod_HiJack, RouteMethod_WebSocket:
+		methods = []string{RouteMethod_GET}
+	default:
+		if !isValidMethod(routeMethod) {
+			return errors.New("illegal method " + routeMethod)
+		}
+		methods = []string{routeMethod}
+	}
+
+	r.nodesMutex.Lock()
+	defer r.nodesMutex.Unlock()
+
+	nodes := r.loadNodes()
+	removes := make(map[string]bool)
+	for _, method := range methods {
+		if _, exists := r.allRouterExpress[method+routerExpressSplit+realPath]; exists {
+			removes[method] = true
+		}
+	}
+	if len(removes) == 0 {
+		return errors.New("route [" + routeMethod + "] [" + realPath + "] not found")
+	}
+	// remove the auto added routes when no other route left on the path
+	autoRoutes := make(map[string]bool)
+	leftRoute := false
+	for method, root := range nodes {
+		if removes[method] {
+			continue
+		}
+		if n := root.getNode(realPath); n != nil && n.handle != nil && n.fullPath == realPath {
+			if n.isAuto {
+				autoRoutes[method] = true
+			} else {
+				leftRoute = true
+			}
+		}
+	}
+	if !leftRoute {
+		for method := range autoRoutes {
+			removes[method] = true
+		}
+	}
+
+	for method := range removes {
+		root := nodes[method].rebuild(func(route *Node) bool {
+			return route.fullPath == realPath
+		})
+		nodes = r.storeNodes(nodes, method, root)
+		delete(r.allRouterExpress, method+routerExpressSplit+realPath)
+	}
+	if !leftRoute {
+		r.removeNames(realPath)
+	}
+	r.server.Logger().Debug("DotWeb:Router:Remove success ["+routeMethod+"] ["+realPath+"]", LogTarget_HttpServer)
+	return nil
+}
+
+// updateRoute apply fn to the route in current tree which n is registered as, return false if not found.
+// When serving, the route is changed on a copy of tree, variants and limit are copied too as they are shared by trees
+func (r *router) updateRoute(n *Node, fn func(route *Node)) bool {
+	r.nodesMutex.Lock()
+	defer r.nodesMutex.Unlock()
+
+	nodes := r.loadNodes()
+	root := nodes[n.method]
+	if root == nil {
+		return false
+	}
+	serving := r.isServing()
+	if serving {
+		root = root.rebuild(nil)
+	}
+	route := root.getNode(n.fullPath)
+	if route == nil || route.handle == nil || route.fullPath != n.fullPath {
+		return false
+	}
+	if n.version != "" {
+		index := -1
+		for i, v := range route.variants {
+			if v.version == n.version {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return false
+		}
+		if serving {
+			variants := append([]*Node{}, route.variants...)
+			variant := *variants[index]
+			variants[index] = &variant
+			route.variants = variants
+		}
+		route = route.variants[index]
+	}
+	if serving {
+		route.middlewares = append([]Middleware{}, route.middlewares...)
+		if route.limit != nil {
+			limit := *route.limit
+			route.limit = &limit
+		}
+	}
+	fn(route)
+	if serving {
+		r.storeNodes(nodes, n.method, root)
+	}
+	if n.detached {
+		n.copyRoute(route)
+	}
+	return true
+}
+
+// isServing check routes may be looked up by requests, routes changed after that must be copy-on-write
+func (r *router) isServing() bool {
+	return r.published.Load() || (r.server != nil && r.server.serving.Load())
+}
+
+// publish mark the router is serving, it waits the route change in progress,
+// which is done in place, before the first lookup
+func (r *router) publish() {
+	r.nodesMutex.Lock()
+	r.published.Store(true)
+	r.nodesMutex.Unlock()
+}
+
+// loadNodes return current route trees, the returned map must not be modified
+func (r *router) loadNodes() map[string]*Node {
+	nodes, _ := r.nodes.Load().(map[string]*Node)
+	return nodes
+}
+
+// storeNodes publish a copy of nodes with root of method replaced, empty root is removed
+// must be called with nodesMutex locked
+func (r *router) storeNodes(nodes map[string]*Node, method string, root *Node) map[string]*Node {
+	newNodes := make(map[string]*Node, len(nodes)+1)
+	for k, v := range nodes {
+		newNodes[k] = v
+	}
+	if root.isEmpty() {
+		delete(newNodes, method)
+	} else {
+		newNodes[method] = root
+	}
+	r.nodes.Store(newNodes)
+	return newNodes
 }
 
 func (r *router) allowed(path, reqMethod string) (allow string) {
+	nodes := r.loadNodes()
 	if path == "*" { // server-wide
-		for method := range r.Nodes {
+		for method := range nodes {
 			if method == "OPTIONS" {
 				continue
 			}
@@ -503,13 +1159,13 @@ func (r *router) allowed(path, reqMethod string) (allow string) {
 			}
 		}
 	} else { // specific path
-		for method := range r.Nodes {
+		for method := range nodes {
 			// Skip the requested method - we already tried this one
 			if method == reqMethod || method == "OPTIONS" {
 				continue
 			}
 
-			handle, _, _, _ := r.Nodes[method].getValue(path)
+			handle, _, _, _ := nodes[method].getValue(path)
 			if handle != nil {
 				// add request method to list of allowed methods
 				if len(allow) == 0 {
@@ -545,28 +1201,30 @@ func (r *router) wrapRouterHandle(handler HttpHandle, isHijack bool) RouterHandl
 		defer func() {
 			var errmsg string
 			if err := recover(); err != nil {
+				// let net/http abort the response silently
+				if err == http.ErrAbortHandler {
+					if httpCtx.getCancel() != nil {
+						httpCtx.getCancel()()
+					}
+					panic(err)
+				}
 				errmsg = exception.CatchError("HttpServer::RouterHandle", LogTarget_HttpServer, err)
 
 				// handler the exception
 				if r.server.DotApp.ExceptionHandler != nil {
-					r.server.DotApp.ExceptionHandler(httpCtx, fmt.Errorf("%v", err))
+					// keep the error type, handler may reply by it
+					panicErr, ok := err.(error)
+					if !ok {
+						panicErr = fmt.Errorf("%v", err)
+					}
+					r.server.DotApp.ExceptionHandler(httpCtx, panicErr)
 				}
 
 				// if set enabledLog, take the error log
-				if r.server.Logger().IsEnabledLog() {
-					// record access log
-					headinfo := fmt.Sprintln(httpCtx.Response().Header())
-					logJson := LogJson{
-						RequestUrl: httpCtx.Request().RequestURI,
-						HttpHeader: headinfo,
-						HttpBody:   errmsg,
-					}
-					logString := jsonutil.GetJsonString(logJson)
-					r.server.Logger().Error(logString, LogTarget_HttpServer)
-				}
+				logPanic(httpCtx, errmsg)
 
 				// Increment error count
-				r.server.StateInfo().AddErrorCount(httpCtx.Request().Path(), fmt.Errorf("%v", err), 1)
+				countError(httpCtx, fmt.Errorf("%v", err))
 			}
 
 			// cancle Context
@@ -597,7 +1255,7 @@ func (r *router) wrapRouterHandle(handler HttpHandle, isHijack bool) RouterHandl
 			if r.server.DotApp.ExceptionHandler != nil {
 				r.server.DotApp.ExceptionHandler(httpCtx, ctxErr)
 				// increment error count
-				r.server.StateInfo().AddErrorCount(httpCtx.Request().Path(), ctxErr, 1)
+				countError(httpCtx, ctxErr)
 			}
 		}
 
@@ -616,7 +1274,7 @@ func (r *router) wrapFileHandle(fileHandler http.Handler, excludeExtension []str
 			if ctxErr != nil {
 				if r.server.DotApp.ExceptionHandler != nil {
 					r.server.DotApp.ExceptionHandler(httpCtx, ctxErr)
-					r.server.StateInfo().AddErrorCount(httpCtx.Request().Path(), ctxErr, 1)
+					countError(httpCtx, ctxErr)
 				}
 			}
 		} else {
@@ -629,51 +1287,29 @@ func (r *router) wrapFileHandle(fileHandler http.Handler, excludeExtension []str
 	}
 }
 
-// wrap HttpHandle to websocket.Handle
-func (r *router) wrapWebSocketHandle(handler HttpHandle) websocket.Handler {
-	return func(ws *websocket.Conn) {
-		// get from pool
-		req := r.server.pool.request.Get().(*Request)
-		httpCtx := r.server.pool.context.Get().(*HttpContext)
-		httpCtx.reset(nil, req, r.server, nil, nil, handler)
-		req.reset(ws.Request(), httpCtx)
-		httpCtx.webSocket = &WebSocket{
-			Conn: ws,
+// wrap HttpHandle to do websocket handshake before call it, the handshake is done after middlewares,
+// the connection is closed when handler returns
+func (r *router) wrapWebSocketHandle(handler HttpHandle) HttpHandle {
+	return func(httpCtx Context) error {
+		ws, code, err := upgradeWebSocket(httpCtx, r.server.WebSocketConfig())
+		if err != nil {
+			if code == 0 {
+				return err
+			}
+			return httpCtx.WriteStringC(code, err.Error())
 		}
-		httpCtx.isWebSocket = true
-
-		startTime := time.Now()
+		httpCtx.setWebSocket(ws)
+		r.server.StateInfo().AddWebSocket(1)
+		// close with internal error if handler fails or panics
+		closeCode := CloseInternalServerErr
 		defer func() {
-			var errmsg string
-			if err := recover(); err != nil {
-				errmsg = exception.CatchError("httpserver::WebsocketHandle", LogTarget_HttpServer, err)
-
-				// record access log
-				headinfo := fmt.Sprintln(httpCtx.webSocket.Request().Header)
-				logJson := LogJson{
-					RequestUrl: httpCtx.webSocket.Request().RequestURI,
-					HttpHeader: headinfo,
-					HttpBody:   errmsg,
-				}
-				logString := jsonutil.GetJsonString(logJson)
-				r.server.Logger().Error(logString, LogTarget_HttpServer)
-
-				// increment error count
-				r.server.StateInfo().AddErrorCount(httpCtx.Request().Path(), fmt.Errorf("%v", err), 1)
-			}
-			timetaken := int64(time.Now().Sub(startTime) / time.Millisecond)
-			// HttpServer Logging
-			r.server.Logger().Debug(httpCtx.Request().Url()+" "+logWebsocketContext(httpCtx, timetaken), LogTarget_HttpRequest)
-
-			// release request
-			req.release()
-			r.server.pool.request.Put(req)
-			// release context
-			httpCtx.release()
-			r.server.pool.context.Put(httpCtx)
+			ws.finish(closeCode)
+			r.server.StateInfo().SubWebSocket(1)
 		}()
-
-		handler(httpCtx)
+		if err = handler(httpCtx); err == nil {
+			closeCode = CloseNormalClosure
+		}
+		return err
 	}
 }
 
@@ -685,6 +1321,33 @@ func transferHandlerFunc(handlerFunc http.HandlerFunc) HttpHandle {
 	}
 }
 
+// transferMountHandler transfer mounted http.Handler to HttpHandle, the prefix is stripped from request path
+func transferMountHandler(prefix string, handler http.Handler) HttpHandle {
+	return func(httpCtx Context) error {
+		req := httpCtx.Request().Request
+		r2 := new(http.Request)
+		*r2 = *req
+		r2.URL = new(url.URL)
+		*r2.URL = *req.URL
+		r2.URL.Path = stripMountPrefix(req.URL.Path, prefix)
+		if req.URL.RawPath != "" {
+			r2.URL.RawPath = stripMountPrefix(req.URL.RawPath, prefix)
+		}
+		r2.RequestURI = r2.URL.RequestURI()
+		handler.ServeHTTP(&responseWriter{res: httpCtx.Response()}, r2)
+		return nil
+	}
+}
+
+// stripMountPrefix remove prefix from path, the result always starts with '/'
+func stripMountPrefix(path, prefix string) string {
+	path = strings.TrimPrefix(path, prefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
 // transferStaticFileHandler transfer http.Handler to HttpHandle
 func transferStaticFileHandler(fileHandler http.Handler, excludeExtension []string) HttpHandle {
 	return func(httpCtx Context) error {
@@ -706,34 +1369,34 @@ func transferStaticFileHandler(fileHandler http.Handler, excludeExtension []stri
 }
 
 // existsRouter check is exists with method and path in current router
+// needAutoRoute check the auto HEAD or OPTIONS route should be added for the route registered by group g,
+// the version variant and the route without version on the same path need their own auto routes
+func (r *router) needAutoRoute(method, path string, g *xGroup) bool {
+	if !r.existsRouter(method, path) {
+		return true
+	}
+	node := r.getNode(method, path)
+	if node == nil || node.fullPath != path {
+		return false
+	}
+	if g != nil && g.version != "" {
+		for _, v := range node.variants {
+			if v.version == g.version {
+				return false
+			}
+		}
+		return true
+	}
+	return node.isVersionHolder
+}
+
 func (r *router) existsRouter(method, path string) bool {
+	r.nodesMutex.RLock()
+	defer r.nodesMutex.RUnlock()
 	_, exists := r.allRouterExpress[method+routerExpressSplit+path]
 	return exists
 }
 
-// get default log string
-func logWebsocketContext(ctx Context, timetaken int64) string {
-	var reqbytelen, resbytelen, method, proto, status, userip string
-	if ctx != nil {
-		reqbytelen = convert.Int642String(ctx.Request().ContentLength)
-		resbytelen = "0"
-		method = ctx.Request().Method
-		proto = ctx.Request().Proto
-		status = "0"
-		userip = ctx.RemoteIP()
-	}
-
-	log := method + " "
-	log += userip + " "
-	log += proto + " "
-	log += status + " "
-	log += reqbytelen + " "
-	log += resbytelen + " "
-	log += convert.Int642String(timetaken)
-
-	return log
-}
-
 func logRequest(req *http.Request, timetaken int64) string {
 	var reqbytelen, resbytelen, method, proto, status, userip string
 	reqbytelen = convert.Int642String(req.ContentLength)
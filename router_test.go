@@ -0,0 +1,512 @@
+package dotweb
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+// prepareTestApp init app environment like ListenAndServe, but without listen
+func prepareTestApp(app *DotWeb) *DotWeb {
+	app.initServerEnvironment()
+	app.initBindMiddleware()
+	app.HttpServer.serving.Store(true)
+	return app
+}
+
+// doTestRequest serve request with app.HttpServer and return the recorder
+func doTestRequest(app *DotWeb, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		for _, value := range v {
+			req.Header.Add(k, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	app.HttpServer.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouter_ParamConstraint(t *testing.T) {
+	app := New()
+	app.HttpServer.GET("/user/:id<int>", func(ctx Context) error {
+		return ctx.WriteString("id=" + ctx.GetRouterName("id"))
+	})
+	app.HttpServer.GET("/user/:id<int>/posts", func(ctx Context) error {
+		return ctx.WriteString("posts=" + ctx.GetRouterName("id"))
+	})
+	app.HttpServer.GET("/user/:name/profile", func(ctx Context) error {
+		return ctx.WriteString("profile=" + ctx.GetRouterName("name"))
+	})
+	prepareTestApp(app)
+
+	// backtrack to the unconstrained param when the rest of path is not matched
+	w := doTestRequest(app, "GET", "/user/123/profile", nil)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "profile=123", w.Body.String())
+	w = doTestRequest(app, "GET", "/user/123/posts", nil)
+	test.Equal(t, "posts=123", w.Body.String())
+	w = doTestRequest(app, "GET", "/user/tom/profile", nil)
+	test.Equal(t, "profile=tom", w.Body.String())
+	test.Equal(t, http.StatusNotFound, doTestRequest(app, "GET", "/user/tom/posts", nil).Code)
+
+	w = doTestRequest(app, "GET", "/user/12", nil)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "id=12", w.Body.String())
+
+	w = doTestRequest(app, "GET", "/user/abc", nil)
+	test.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doTestRequest(app, "POST", "/user/12", nil)
+	test.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestRouter_URL(t *testing.T) {
+	app := New()
+	app.HttpServer.SetVirtualPath("/app")
+	handler := func(ctx Context) error {
+		return nil
+	}
+	g := app.HttpServer.Group("/api")
+	g.GET("/user/:id<int>", handler).Name("user.show")
+	g.GET("/user/:id<int>/post/:title", handler).Name("user.post")
+	app.HttpServer.ServerFile("/static/*filepath", "./").Name("static")
+
+	r := app.HttpServer.Router()
+	url, err := r.URL("user.show", 12)
+	test.Nil(t, err)
+	test.Equal(t, "/app/api/user/12", url)
+
+	url, err = r.URL("user.post", 1, "a b/c")
+	test.Nil(t, err)
+	test.Equal(t, "/app/api/user/1/post/a%20b%2Fc", url)
+
+	url, err = r.URL("static", "/css/a b.css")
+	test.Nil(t, err)
+	test.Equal(t, "/app/static/css/a%20b.css", url)
+
+	_, err = r.URL("user.show", "abc")
+	test.NotNil(t, err)
+	_, err = r.URL("user.show")
+	test.NotNil(t, err)
+	_, err = r.URL("not.exists")
+	test.NotNil(t, err)
+
+	ctx := &HttpContext{httpServer: app.HttpServer}
+	url, err = ctx.URLFor("user.post", "title", "hello", "id", 3, "page", 2)
+	test.Nil(t, err)
+	test.Equal(t, "/app/api/user/3/post/hello?page=2", url)
+}
+
+func TestRouter_Routes(t *testing.T) {
+	app := New()
+	handler := func(ctx Context) error {
+		return nil
+	}
+	app.Use(&testRoutesMiddleware{})
+	g := app.HttpServer.Group("/api").Use(&testRoutesMiddleware{})
+	g.GET("/user/:id", handler).Name("user.show").Use(&testRoutesMiddleware{})
+	app.HttpServer.HiJack("/hijack", handler)
+	app.HttpServer.WebSocket("/ws/routes", handler)
+	app.HttpServer.ServerFile("/static/*", "./")
+	prepareTestApp(app)
+
+	routes := app.HttpServer.Router().Routes()
+	find := func(method, path string) *RouteInfo {
+		for i := range routes {
+			if routes[i].Method == method && routes[i].Path == path {
+				return &routes[i]
+			}
+		}
+		return nil
+	}
+
+	user := find("GET", "/api/user/:id")
+	test.NotNil(t, user)
+	test.Equal(t, "user.show", user.Name)
+	test.Equal(t, "/api", user.Group)
+	test.Equal(t, true, strings.HasSuffix(user.Handler, "TestRouter_Routes.func1"))
+	test.Equal(t, []string{"*dotweb.testRoutesMiddleware"}, user.AppMiddlewares)
+	test.Equal(t, []string{"*dotweb.testRoutesMiddleware"}, user.GroupMiddlewares)
+	test.Equal(t, []string{"*dotweb.testRoutesMiddleware"}, user.Middlewares)
+
+	test.Equal(t, true, find("GET", "/hijack").IsHijack)
+	test.Equal(t, true, find("WEBSOCKET", "/ws/routes").IsWebSocket)
+	test.Equal(t, true, find("GET", "/static/*filepath").IsStatic)
+}
+
+type testRoutesMiddleware struct {
+	BaseMiddleware
+}
+
+func (m *testRoutesMiddleware) Handle(ctx Context) error {
+	return m.Next(ctx)
+}
+
+func TestRouter_RemoveAndHotRegister(t *testing.T) {
+	app := New()
+	app.HttpServer.SetEnabledAutoHEAD(true)
+	app.Use(&testHeaderMiddleware{})
+	app.HttpServer.GET("/a", func(ctx Context) error {
+		return ctx.WriteString("a")
+	})
+	app.HttpServer.GET("/a/:id", func(ctx Context) error {
+		return ctx.WriteString("a" + ctx.GetRouterName("id"))
+	})
+	prepareTestApp(app)
+
+	// register when serving, app middlewares are bound
+	app.HttpServer.GET("/b", func(ctx Context) error {
+		return ctx.WriteString("b")
+	})
+	w := doTestRequest(app, "GET", "/b", nil)
+	test.Equal(t, "b", w.Body.String())
+	test.Equal(t, "1", w.Header().Get("X-Test-Middleware"))
+
+	r := app.HttpServer.Router()
+	test.Nil(t, r.Remove("GET", "/a"))
+	test.Equal(t, http.StatusNotFound, doTestRequest(app, "GET", "/a", nil).Code)
+	test.Equal(t, http.StatusNotFound, doTestRequest(app, "HEAD", "/a", nil).Code)
+	test.Equal(t, "a1", doTestRequest(app, "GET", "/a/1", nil).Body.String())
+	test.NotNil(t, r.Remove("GET", "/a"))
+	test.NotNil(t, r.Remove("WEBSOCKET", "/a"))
+	_, exists := r.GetAllRouterExpress()["GET"+routerExpressSplit+"/a"]
+	test.Equal(t, false, exists)
+}
+
+func TestRouter_HotRegisterConcurrent(t *testing.T) {
+	app := New()
+	app.HttpServer.GET("/ping", func(ctx Context) error {
+		return ctx.WriteString("pong")
+	})
+	prepareTestApp(app)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			path := "/hot/" + strconv.Itoa(i)
+			app.HttpServer.GET(path, func(ctx Context) error {
+				return nil
+			})
+			if i%2 == 0 {
+				app.HttpServer.Router().Remove("GET", path)
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		w := doTestRequest(app, "GET", "/ping", nil)
+		test.Equal(t, "pong", w.Body.String())
+	}
+	<-done
+	test.Equal(t, http.StatusNotFound, doTestRequest(app, "GET", "/hot/0", nil).Code)
+	test.Equal(t, http.StatusOK, doTestRequest(app, "GET", "/hot/1", nil).Code)
+}
+
+func TestRouter_StaleNode(t *testing.T) {
+	app := New()
+	handler := func(ctx Context) error {
+		return ctx.WriteString(ctx.Request().Path())
+	}
+	ab := app.HttpServer.GET("/ab", handler)
+	// split the edge of /ab, the node of /ab is copied
+	app.HttpServer.GET("/a", handler)
+	ab.Use(&testHeaderMiddleware{})
+	prepareTestApp(app)
+	test.Equal(t, "1", doTestRequest(app, "GET", "/ab", nil).Header().Get("X-Test-Middleware"))
+
+	// the tree is rebuilt by each change when serving
+	c := app.HttpServer.GET("/c", handler)
+	app.HttpServer.GET("/d", handler)
+	c.Use(&testHeaderMiddleware{}).Name("c").MaxBody(4)
+	w := doTestRequest(app, "GET", "/c", nil)
+	test.Equal(t, "/c", w.Body.String())
+	test.Equal(t, "1", w.Header().Get("X-Test-Middleware"))
+	url, err := app.HttpServer.Router().URL("c")
+	test.Nil(t, err)
+	test.Equal(t, "/c", url)
+	node := app.HttpServer.Router().(*router).getNode("GET", "/c")
+	test.Equal(t, int64(4), node.limit.maxBody)
+	test.Equal(t, "c", node.RouteName())
+}
+
+func TestRouter_HotRegisterConfigure(t *testing.T) {
+	app := New()
+	prepareTestApp(app)
+
+	// run with -race, the route must not be changed after it is published
+	const count = 20
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				doTestRequest(app, "GET", "/hot/"+strconv.Itoa(j%count), nil)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		app.HttpServer.GET("/hot/"+strconv.Itoa(i), func(ctx Context) error {
+			return ctx.WriteString("hot")
+		}).Use(&testHeaderMiddleware{}).Timeout(time.Second).MaxConcurrent(100)
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < count; i++ {
+		w := doTestRequest(app, "GET", "/hot/"+strconv.Itoa(i), nil)
+		test.Equal(t, "hot", w.Body.String())
+		test.Equal(t, "1", w.Header().Get("X-Test-Middleware"))
+	}
+}
+
+func TestRouter_HotRegisterWithoutStart(t *testing.T) {
+	app := New()
+	app.HttpServer.GET("/a", func(ctx Context) error {
+		return ctx.WriteString("a")
+	})
+	// served by httptest without DotWeb start, run with -race
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if res, err := http.Get(server.URL + "/a"); err == nil {
+					res.Body.Close()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		app.HttpServer.GET("/"+strconv.Itoa(i), func(ctx Context) error {
+			return ctx.WriteString("hot")
+		})
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	res, err := http.Get(server.URL + "/49")
+	test.Nil(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	test.Equal(t, "hot", string(body))
+	test.Equal(t, "a", doTestRequest(app, "GET", "/a", nil).Body.String())
+}
+
+type testHeaderMiddleware struct {
+	BaseMiddleware
+}
+
+func (m *testHeaderMiddleware) Handle(ctx Context) error {
+	ctx.Response().SetHeader("X-Test-Middleware", "1")
+	return m.Next(ctx)
+}
+
+func TestRouter_CustomMethodAndOverride(t *testing.T) {
+	RegisterHttpMethod("PROPFIND", "purge", "REPORT")
+	app := New()
+	app.Use(NewMethodOverrideMiddleware(MethodOverrideConfig{}))
+	app.HttpServer.Any("/res", func(ctx Context) error {
+		body, _ := io.ReadAll(ctx.Request().Body)
+		return ctx.WriteString(ctx.Request().Method + " " + string(body))
+	})
+	app.HttpServer.RegisterRoute("REPORT", "/report", func(ctx Context) error {
+		return ctx.WriteString("report")
+	})
+	app.HttpServer.GET("/report", func(ctx Context) error {
+		return nil
+	})
+	prepareTestApp(app)
+
+	test.Equal(t, "PROPFIND ", doTestRequest(app, "PROPFIND", "/res", nil).Body.String())
+	test.Equal(t, "PURGE ", doTestRequest(app, "PURGE", "/res", nil).Body.String())
+	test.Equal(t, "REPORT ", doTestRequest(app, "REPORT", "/res", nil).Body.String())
+
+	w := doTestRequest(app, "DELETE", "/report", nil)
+	test.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	test.Equal(t, true, strings.Contains(w.Header().Get("Allow"), "REPORT"))
+
+	w = doTestRequest(app, "POST", "/res", http.Header{HeaderXHTTPMethodOverride: []string{"purge"}})
+	test.Equal(t, "PURGE ", w.Body.String())
+
+	post := func(contentType, body string) string {
+		req := httptest.NewRequest("POST", "/res", strings.NewReader(body))
+		req.Header.Set(HeaderContentType, contentType)
+		w := httptest.NewRecorder()
+		app.HttpServer.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	// body is still readable by handler
+	test.Equal(t, "DELETE _method=DELETE&a=1", post(MIMEApplicationForm, "_method=DELETE&a=1"))
+	// json and multipart bodies are not read
+	test.Equal(t, `POST {"_method":"DELETE"}`, post(MIMEApplicationJSON, `{"_method":"DELETE"}`))
+	multipart := "--b\r\nContent-Disposition: form-data; name=\"_method\"\r\n\r\nDELETE\r\n--b--\r\n"
+	test.Equal(t, "POST "+multipart, post(MIMEMultipartForm+"; boundary=b", multipart))
+
+	// only POST can be overridden
+	w = doTestRequest(app, "GET", "/res", http.Header{HeaderXHTTPMethodOverride: []string{"DELETE"}})
+	test.Equal(t, "GET ", w.Body.String())
+}
+
+func TestRouter_Mount(t *testing.T) {
+	app := New()
+	app.Use(&testHeaderMiddleware{})
+	moduleCalled := false
+	app.HttpServer.RegisterModule(&HttpModule{
+		Name: "test",
+		OnBeginRequest: func(ctx Context) {
+			moduleCalled = true
+		},
+	})
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(req.Method + " " + req.URL.Path))
+	})
+	app.HttpServer.Mount("/legacy", legacy)
+	app.HttpServer.Group("/api").Mount("/v1/", legacy)
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "POST", "/legacy/a/b?x=1", nil)
+	test.Equal(t, http.StatusAccepted, w.Code)
+	test.Equal(t, "POST /a/b", w.Body.String())
+	test.Equal(t, "1", w.Header().Get("X-Test-Middleware"))
+	test.Equal(t, true, moduleCalled)
+
+	test.Equal(t, "GET /", doTestRequest(app, "GET", "/legacy", nil).Body.String())
+	test.Equal(t, "GET /user", doTestRequest(app, "GET", "/api/v1/user", nil).Body.String())
+	test.Equal(t, http.StatusNotFound, doTestRequest(app, "GET", "/legacyx", nil).Code)
+}
+
+func TestRouter_RouteLimits(t *testing.T) {
+	app := New()
+	app.HttpServer.POST("/upload", func(ctx Context) error {
+		return ctx.WriteString(strconv.Itoa(len(ctx.Request().PostBody())))
+	}).MaxBody(8)
+	app.HttpServer.GET("/slow", func(ctx Context) error {
+		<-ctx.Context().Done()
+		return nil
+	}).Timeout(20 * time.Millisecond)
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	app.HttpServer.GET("/busy", func(ctx Context) error {
+		entered <- struct{}{}
+		<-release
+		return ctx.WriteString("done")
+	}).MaxConcurrent(1)
+	prepareTestApp(app)
+
+	post := func(body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/upload", body)
+		w := httptest.NewRecorder()
+		app.HttpServer.ServeHTTP(w, req)
+		return w
+	}
+	w := post(strings.NewReader("1234"))
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "4", w.Body.String())
+	// known Content-Length is rejected before handler
+	test.Equal(t, http.StatusRequestEntityTooLarge, post(strings.NewReader("123456789")).Code)
+	// unknown Content-Length is rejected when reading body
+	test.Equal(t, http.StatusRequestEntityTooLarge, post(io.MultiReader(strings.NewReader("123456789"))).Code)
+
+	test.Equal(t, http.StatusGatewayTimeout, doTestRequest(app, "GET", "/slow", nil).Code)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- doTestRequest(app, "GET", "/busy", nil)
+	}()
+	<-entered
+	test.Equal(t, http.StatusServiceUnavailable, doTestRequest(app, "GET", "/busy", nil).Code)
+	close(release)
+	test.Equal(t, "done", (<-done).Body.String())
+
+	state := app.HttpServer.StateInfo()
+	test.Equal(t, uint64(2), state.RouteLimitData.GetUInt64(RouteLimit_MaxBody+":/upload"))
+	test.Equal(t, uint64(1), state.RouteLimitData.GetUInt64(RouteLimit_Timeout+":/slow"))
+	test.Equal(t, uint64(1), state.RouteLimitData.GetUInt64(RouteLimit_MaxConcurrent+":/busy"))
+}
+
+func TestRouter_RouteTimeoutIgnored(t *testing.T) {
+	app := New()
+	writeErr := make(chan error, 1)
+	app.HttpServer.GET("/sleep", func(ctx Context) error {
+		// ignore Context.Context()
+		time.Sleep(300 * time.Millisecond)
+		_, err := ctx.Response().Write(http.StatusOK, []byte("late"))
+		writeErr <- err
+		return nil
+	}).Timeout(50 * time.Millisecond)
+	app.HttpServer.GET("/fast", func(ctx Context) error {
+		ctx.Response().Header().Set("X-Fast", "1")
+		return ctx.WriteStringC(http.StatusCreated, "fast")
+	}).Timeout(time.Second)
+	prepareTestApp(app)
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	begin := time.Now()
+	res, err := http.Get(server.URL + "/sleep")
+	test.Nil(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	test.Equal(t, true, time.Since(begin) < 250*time.Millisecond)
+	test.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	test.Equal(t, http.StatusText(http.StatusGatewayTimeout), string(body))
+	test.Equal(t, http.ErrHandlerTimeout, <-writeErr)
+
+	w := doTestRequest(app, "GET", "/fast", nil)
+	test.Equal(t, http.StatusCreated, w.Code)
+	test.Equal(t, "1", w.Header().Get("X-Fast"))
+	test.Equal(t, "fast", w.Body.String())
+	test.Equal(t, uint64(1), app.HttpServer.StateInfo().RouteLimitData.GetUInt64(RouteLimit_Timeout+":/sleep"))
+}
+
+func TestRouter_RouteTimeoutGzip(t *testing.T) {
+	app := New()
+	app.HttpServer.SetEnabledGzip(true)
+	app.HttpServer.GET("/sleep", func(ctx Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return ctx.WriteString("late")
+	}).Timeout(20 * time.Millisecond)
+	prepareTestApp(app)
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/sleep")
+	test.Nil(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	test.Nil(t, err)
+	test.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	test.Equal(t, "", res.Header.Get(HeaderContentEncoding))
+	test.Equal(t, http.StatusText(http.StatusGatewayTimeout), string(body))
+	// wait the handler returns and response is released
+	time.Sleep(150 * time.Millisecond)
+}
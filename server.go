@@ -2,12 +2,13 @@ package dotweb
 
 import (
 	"compress/gzip"
+	"fmt"
 	"github.com/devfeel/dotweb/logger"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/devfeel/dotweb/core"
@@ -31,6 +32,7 @@ type (
 		stdServer      *http.Server
 		router         Router
 		groups         []*xGroup
+		hosts          []*hostRoute
 		Modules        []*HttpModule
 		DotApp         *DotWeb
 		Validator      Validator
@@ -39,8 +41,16 @@ type (
 		pool           *pool
 		contextCreater ContextCreater
 		binder         Binder
+		encoders       *encoderRegistry
+		jsonLimit      JsonStreamLimit
+		flushPolicy    StreamFlushPolicy
+		sseConfig      SSEConfig
+		wsConfig       WebSocketConfig
 		render         Renderer
 		offline        bool
+		// serving is set when server begin to serve requests,
+		// routes changed after that are published by copy-on-write
+		serving atomic.Bool
 	}
 
 	pool struct {
@@ -58,6 +68,11 @@ func NewHttpServer() *HttpServer {
 		Modules:        make([]*HttpModule, 0),
 		lock_session:   new(sync.RWMutex),
 		binder:         newBinder(),
+		encoders:       newEncoderRegistry(),
+		jsonLimit:      DefaultJsonStreamLimit(),
+		flushPolicy:    DefaultStreamFlushPolicy(),
+		sseConfig:      DefaultSSEConfig(),
+		wsConfig:       DefaultWebSocketConfig(),
 		contextCreater: defaultContextCreater,
 	}
 	server.pool = &pool{
@@ -168,35 +183,31 @@ func (server *HttpServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	server.StateInfo().AddCurrentRequest(1)
 	defer server.StateInfo().SubCurrentRequest(1)
 
-	// special handling for websocket and debugging
-	if checkIsWebSocketRequest(req) {
-		http.DefaultServeMux.ServeHTTP(w, req)
-		server.StateInfo().AddRequestCount(req.URL.Path, defaultHttpCode, 1)
-	} else {
-		// setup header
-		w.Header().Set(HeaderServer, DefaultServerName)
-		httpCtx := prepareHttpContext(server, w, req)
-		// process OnBeginRequest of modules
-		for _, module := range server.Modules {
-			if module.OnBeginRequest != nil {
-				module.OnBeginRequest(httpCtx)
-			}
+	// setup header
+	w.Header().Set(HeaderServer, DefaultServerName)
+	httpCtx := prepareHttpContext(server, w, req)
+	// process OnBeginRequest of modules
+	for _, module := range server.Modules {
+		if module.OnBeginRequest != nil {
+			module.OnBeginRequest(httpCtx)
 		}
+	}
 
-		if !httpCtx.IsEnd() {
-			server.Router().ServeHTTP(httpCtx)
-		}
+	if !httpCtx.IsEnd() {
+		router, hostParams := server.routerForHost(req.Host)
+		httpCtx.setRouterParams(hostParams)
+		router.ServeHTTP(httpCtx)
+	}
 
-		// process OnEndRequest of modules
-		for _, module := range server.Modules {
-			if module.OnEndRequest != nil {
-				module.OnEndRequest(httpCtx)
-			}
+	// process OnEndRequest of modules
+	for _, module := range server.Modules {
+		if module.OnEndRequest != nil {
+			module.OnEndRequest(httpCtx)
 		}
-		server.StateInfo().AddRequestCount(httpCtx.Request().Path(), httpCtx.Response().HttpCode(), 1)
-
-		releaseHttpContext(server, httpCtx)
 	}
+	server.StateInfo().AddRequestCount(httpCtx.Request().Path(), httpCtx.Response().HttpCode(), 1)
+
+	releaseHttpContext(server, httpCtx)
 }
 
 // IsOffline check server is set offline state
@@ -376,8 +387,13 @@ func (server *HttpServer) HiJack(path string, handle HttpHandle) {
 }
 
 // WebSocket is a shortcut for router.WebSocket(path, handle)
-func (server *HttpServer) WebSocket(path string, handle HttpHandle) {
-	server.Router().WebSocket(path, handle)
+func (server *HttpServer) WebSocket(path string, handle HttpHandle) RouterNode {
+	return server.Router().WebSocket(path, handle)
+}
+
+// Mount is a shortcut for router.Mount(prefix, handler)
+func (server *HttpServer) Mount(prefix string, handler http.Handler) {
+	server.Router().Mount(prefix, handler)
 }
 
 // Group create new group with current HttpServer
@@ -390,6 +406,58 @@ func (server *HttpServer) Binder() Binder {
 	return server.binder
 }
 
+// SetJsonStreamLimit set the limits used by Context.BindJsonStream and Context.ReadNDJSON
+func (server *HttpServer) SetJsonStreamLimit(limit JsonStreamLimit) {
+	server.jsonLimit = limit
+	server.Logger().Debug("DotWeb:HttpServer SetJsonStreamLimit ["+fmt.Sprint(limit)+"]", LogTarget_HttpServer)
+}
+
+// JsonStreamLimit get the limits used by Context.BindJsonStream and Context.ReadNDJSON
+func (server *HttpServer) JsonStreamLimit() JsonStreamLimit {
+	return server.jsonLimit
+}
+
+// SetStreamFlushPolicy set when Context.StreamJSONArray, StreamNDJSON and StreamCSV flush the response
+func (server *HttpServer) SetStreamFlushPolicy(policy StreamFlushPolicy) {
+	server.flushPolicy = policy
+	server.Logger().Debug("DotWeb:HttpServer SetStreamFlushPolicy ["+fmt.Sprint(policy)+"]", LogTarget_HttpServer)
+}
+
+// StreamFlushPolicy get the flush policy of streaming helpers
+func (server *HttpServer) StreamFlushPolicy() StreamFlushPolicy {
+	return server.flushPolicy
+}
+
+// SetWebSocketConfig set the config of websocket routes, it takes effect on new connections
+func (server *HttpServer) SetWebSocketConfig(config WebSocketConfig) {
+	server.wsConfig = config
+	server.Logger().Debug("DotWeb:HttpServer SetWebSocketConfig [maxmessagesize:"+strconv.FormatInt(config.MaxMessageSize, 10)+", compression:"+strconv.FormatBool(config.EnableCompression)+"]", LogTarget_HttpServer)
+}
+
+// WebSocketConfig get the config of websocket routes
+func (server *HttpServer) WebSocketConfig() WebSocketConfig {
+	return server.wsConfig
+}
+
+// SetSSEConfig set the config of Server-Sent Events streams created by Context.SSE
+func (server *HttpServer) SetSSEConfig(config SSEConfig) {
+	server.sseConfig = config
+	server.Logger().Debug("DotWeb:HttpServer SetSSEConfig [keepalive:"+config.KeepAlive.String()+"]", LogTarget_HttpServer)
+}
+
+// SSEConfig get the config of Server-Sent Events streams
+func (server *HttpServer) SSEConfig() SSEConfig {
+	return server.sseConfig
+}
+
+// RegisterEncoder registers the encoder used by Context.Negotiate for the content type,
+// the content type is matched with Accept by media type and written as Content-Type header.
+// Built-in encoders are json, xml, yaml and text/plain, the encoder with same media type is replaced,
+// new encoder is appended and has lower priority when Accept is empty or */*
+func (server *HttpServer) RegisterEncoder(contentType string, encoder Encoder) {
+	server.encoders.register(contentType, encoder)
+}
+
 // Renderer get renderer interface in server
 // if no set, init InnerRenderer
 func (server *HttpServer) Renderer() Renderer {
@@ -425,6 +493,15 @@ func (server *HttpServer) SetEnabledAutoOPTIONS(isEnabled bool) {
 	server.Logger().Debug("DotWeb:HttpServer SetEnabledAutoOPTIONS ["+strconv.FormatBool(isEnabled)+"]", LogTarget_HttpServer)
 }
 
+// SetEnabledAutoCORSPreflight set CORS preflight reply of CorsMiddleware use the route's allowed methods
+// as Access-Control-Allow-Methods instead of CorsConfig.AllowMethods, origins and headers are still checked by
+// the CorsMiddleware of route, group or app, no CORS header is sent without CorsMiddleware
+// default is false
+func (server *HttpServer) SetEnabledAutoCORSPreflight(isEnabled bool) {
+	server.ServerConfig().EnabledAutoCORSPreflight = isEnabled
+	server.Logger().Debug("DotWeb:HttpServer SetEnabledAutoCORSPreflight ["+strconv.FormatBool(isEnabled)+"]", LogTarget_HttpServer)
+}
+
 // SetEnabledRequestID set create unique request id per request
 // set EnabledRequestID true or false
 // default is false
@@ -457,6 +534,13 @@ func (server *HttpServer) SetEnabledBindUseJsonTag(isEnabled bool) {
 	server.Logger().Debug("DotWeb:HttpServer SetEnabledBindUseJsonTag ["+strconv.FormatBool(isEnabled)+"]", LogTarget_HttpServer)
 }
 
+// SetEnabledBindValidate set whether to validate the struct by Validator after Bind, default is false,
+// Validator must be set, e.g. NewValidator(), or Bind returns ErrValidatorNotRegistered
+func (server *HttpServer) SetEnabledBindValidate(isEnabled bool) {
+	server.ServerConfig().EnabledBindValidate = isEnabled
+	server.Logger().Debug("DotWeb:HttpServer SetEnabledBindValidate ["+strconv.FormatBool(isEnabled)+"]", LogTarget_HttpServer)
+}
+
 // SetEnabledIgnoreFavicon set IgnoreFavicon Enabled
 // default is false
 func (server *HttpServer) SetEnabledIgnoreFavicon(isEnabled bool) {
@@ -530,15 +614,6 @@ type LogJson struct {
 	HttpBody   string
 }
 
-// check request is the websocket request
-// check Connection contains upgrade
-func checkIsWebSocketRequest(req *http.Request) bool {
-	if strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade") {
-		return true
-	}
-	return false
-}
-
 // check request is startwith /debug/
 func checkIsDebugRequest(req *http.Request) bool {
 	if strings.Index(req.RequestURI, "/debug/") == 0 {
@@ -590,10 +665,9 @@ func prepareHttpContext(server *HttpServer, w http.ResponseWriter, req *http.Req
 
 // releaseHttpContext release HttpContext, release gzip writer
 func releaseHttpContext(server *HttpServer, httpCtx Context) {
-	if server.ServerConfig().EnabledGzip {
-		var w io.Writer
-		w = httpCtx.Response().Writer().(*gzipResponseWriter).Writer
-		w.(*gzip.Writer).Close()
+	// gzip may be disabled by the response, e.g. sse
+	if gw, ok := httpCtx.Response().Writer().(*gzipResponseWriter); ok {
+		gw.Writer.(*gzip.Writer).Close()
 	}
 	// release response
 	httpCtx.Response().release()
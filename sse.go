@@ -0,0 +1,210 @@
+package dotweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultSSEKeepAlive = 15 * time.Second
+
+type (
+	// SSEConfig is the config of Server-Sent Events streams created by Context.SSE
+	SSEConfig struct {
+		// KeepAlive is the interval of keep-alive comments, zero disables keep-alive
+		KeepAlive time.Duration
+		// Retry is sent to client as reconnection time when stream opened, zero means not sent
+		Retry time.Duration
+		// Replay is called when stream opened with Last-Event-ID header, used to send the missed events
+		Replay func(ctx Context, sse *SSEWriter, lastEventID string) error
+	}
+
+	// SSEWriter write Server-Sent Events to client, it is safe to use from multiple goroutines
+	// before the handler returns, the stream is closed when handler returns or client disconnects
+	SSEWriter struct {
+		ctx         context.Context
+		w           *responseWriter
+		lastEventID string
+		mutex       sync.Mutex
+		closed      bool
+		stop        chan struct{}
+		stopped     sync.WaitGroup
+	}
+)
+
+// DefaultSSEConfig return the default config, keep-alive comment is sent every 15 seconds
+func DefaultSSEConfig() SSEConfig {
+	return SSEConfig{KeepAlive: defaultSSEKeepAlive}
+}
+
+// SSE start Server-Sent Events stream on the response,
+// gzip is disabled for the response, and open streams are counted in ServerStateInfo.
+// The stream is closed when handler returns, Context.Context() is done when client disconnects
+func (ctx *HttpContext) SSE() (*SSEWriter, error) {
+	if ctx.sse != nil {
+		return ctx.sse, nil
+	}
+	if ctx.IsHijack() || ctx.IsWebSocket() {
+		return nil, errors.New("sse is not supported on hijack or websocket request")
+	}
+	res := ctx.Response()
+	if res.committed {
+		return nil, errors.New("sse must be started before response is written")
+	}
+	if _, ok := res.Writer().(http.Flusher); !ok {
+		return nil, errors.New("the web server does not support flushing")
+	}
+	res.disableGzip()
+	h := res.Header()
+	h.Del(HeaderContentLength)
+	h.Set(HeaderContentType, MIMETextEventStream)
+	h.Set(HeaderCacheControl, "no-cache")
+	// disable proxy buffering, e.g. nginx
+	h.Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	runCtx, cancel := context.WithCancel(ctx.Context())
+	ctx.WithContext(runCtx)
+	sse := &SSEWriter{
+		ctx:         ctx.Context(),
+		w:           &responseWriter{res},
+		lastEventID: ctx.Request().Header.Get(HeaderLastEventID),
+		stop:        make(chan struct{}),
+	}
+	state := ctx.HttpServer().StateInfo()
+	state.AddSSEStream(1)
+	// the stream is closed by the cancel which is called when handler returns
+	parentCancel := ctx.getCancel()
+	ctx.setCancel(func() {
+		sse.close()
+		cancel()
+		state.SubSSEStream(1)
+		if parentCancel != nil {
+			parentCancel()
+		}
+	})
+	ctx.sse = sse
+
+	config := ctx.HttpServer().SSEConfig()
+	if config.Retry > 0 {
+		if err := sse.write("retry: " + strconv.FormatInt(int64(config.Retry/time.Millisecond), 10) + "\n\n"); err != nil {
+			return nil, err
+		}
+	} else {
+		sse.w.Flush()
+	}
+	if sse.lastEventID != "" && config.Replay != nil {
+		if err := config.Replay(ctx, sse, sse.lastEventID); err != nil {
+			return nil, err
+		}
+	}
+	if config.KeepAlive > 0 {
+		sse.stopped.Add(1)
+		go sse.keepAlive(config.KeepAlive)
+	}
+	return sse, nil
+}
+
+// LastEventID return the Last-Event-ID header sent by reconnected client
+func (s *SSEWriter) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done return a channel which is closed when client disconnects or stream is closed
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send send an event, event and id are omitted if empty,
+// data is sent as is if it is string or []byte, otherwise it is encoded as json
+func (s *SSEWriter) Send(event, id string, data interface{}) error {
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		payload = string(b)
+	}
+	var buf bytes.Buffer
+	if id != "" {
+		buf.WriteString("id: " + sseLine(id) + "\n")
+	}
+	if event != "" {
+		buf.WriteString("event: " + sseLine(event) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(payload, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.String())
+}
+
+// SendComment send a comment line which is ignored by client
+func (s *SSEWriter) SendComment(comment string) error {
+	return s.write(": " + sseLine(comment) + "\n\n")
+}
+
+// write write and flush data, return error if stream is closed or client disconnects
+func (s *SSEWriter) write(data string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.New("sse stream is closed")
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write([]byte(data)); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return nil
+}
+
+func (s *SSEWriter) keepAlive(interval time.Duration) {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if s.SendComment("keep-alive") != nil {
+				return
+			}
+		}
+	}
+}
+
+// close stop keep-alive and wait it exit, no write is allowed after close
+func (s *SSEWriter) close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.stop)
+	s.mutex.Unlock()
+	s.stopped.Wait()
+}
+
+// sseLine remove line breaks which break the event format
+func sseLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,63 @@
+package dotweb
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestContext_SSE(t *testing.T) {
+	app := New()
+	app.HttpServer.SetEnabledGzip(true)
+	app.HttpServer.SetSSEConfig(SSEConfig{
+		Retry: 3 * time.Second,
+		Replay: func(ctx Context, sse *SSEWriter, lastEventID string) error {
+			return sse.Send("replay", lastEventID, "missed")
+		},
+	})
+	var openCount uint64
+	app.HttpServer.GET("/events", func(ctx Context) error {
+		sse, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		openCount = ctx.HttpServer().StateInfo().CurrentSSECount
+		if err = sse.Send("", "", "hello"); err != nil {
+			return err
+		}
+		return sse.Send("update", "2", map[string]int{"id": 2})
+	})
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/events", http.Header{HeaderAcceptEncoding: {"gzip"}})
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, MIMETextEventStream, w.Header().Get(HeaderContentType))
+	test.Equal(t, "", w.Header().Get(HeaderContentEncoding))
+	test.Equal(t, "retry: 3000\n\ndata: hello\n\nid: 2\nevent: update\ndata: {\"id\":2}\n\n", w.Body.String())
+	test.Equal(t, uint64(1), openCount)
+	test.Equal(t, uint64(0), app.HttpServer.StateInfo().CurrentSSECount)
+
+	w = doTestRequest(app, "GET", "/events", http.Header{HeaderLastEventID: {"1"}})
+	test.Equal(t, true, strings.HasPrefix(w.Body.String(), "retry: 3000\n\nid: 1\nevent: replay\ndata: missed\n\n"))
+}
+
+func TestContext_SSEKeepAlive(t *testing.T) {
+	app := New()
+	app.HttpServer.SetSSEConfig(SSEConfig{KeepAlive: 5 * time.Millisecond})
+	app.HttpServer.GET("/events", func(ctx Context) error {
+		sse, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		<-sse.Done()
+		return nil
+	}).Timeout(30 * time.Millisecond)
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/events", nil)
+	test.Equal(t, true, strings.Contains(w.Body.String(), ": keep-alive\n\n"))
+	test.Equal(t, uint64(0), app.HttpServer.StateInfo().CurrentSSECount)
+}
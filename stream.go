@@ -0,0 +1,222 @@
+package dotweb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStreamFlushItems    = 100
+	defaultStreamFlushInterval = time.Second
+)
+
+type (
+	// StreamIterator produce items by calling yield, it should stop and return the error when yield returns error,
+	// yield returns error when the write fails or Context.Context() is done
+	StreamIterator func(yield func(item interface{}) error) error
+
+	// StreamFlushPolicy decide when streaming helpers flush the response,
+	// flush after Items items are written or Interval passed since the first item not flushed,
+	// the Interval flush is done by timer, so slow producer does not hold written items. Zero value disables the condition
+	StreamFlushPolicy struct {
+		Items    int
+		Interval time.Duration
+	}
+
+	// streamWriter write stream items to response without keeping body, and flush by policy,
+	// mutex guards the response as it is also flushed by the Interval timer
+	streamWriter struct {
+		ctx     Context
+		w       *responseWriter
+		policy  StreamFlushPolicy
+		mutex   sync.Mutex
+		pending int
+		timer   *time.Timer
+		ended   bool
+		// beforeFlush is called before flush, e.g. flush the buffer of csv.Writer
+		beforeFlush func()
+	}
+)
+
+// DefaultStreamFlushPolicy return the default policy, flush every 100 items or 1 second
+func DefaultStreamFlushPolicy() StreamFlushPolicy {
+	return StreamFlushPolicy{Items: defaultStreamFlushItems, Interval: defaultStreamFlushInterval}
+}
+
+// newStreamWriter set content type and write header, Content-Length is removed as body size is unknown
+func newStreamWriter(ctx Context, contentType string) *streamWriter {
+	res := ctx.Response()
+	res.Header().Del(HeaderContentLength)
+	res.SetContentType(contentType)
+	if !res.committed {
+		res.WriteHeader(http.StatusOK)
+	}
+	return &streamWriter{
+		ctx:    ctx,
+		w:      &responseWriter{res},
+		policy: ctx.HttpServer().StreamFlushPolicy(),
+	}
+}
+
+func (s *streamWriter) Write(b []byte) (int, error) {
+	return s.w.Write(b)
+}
+
+// done return error if Context.Context() is done
+func (s *streamWriter) done() error {
+	return s.ctx.Context().Err()
+}
+
+// writeItem call write with mutex held and count the item, the response is flushed after Items items,
+// and the Interval timer is started by the first item not flushed
+func (s *streamWriter) writeItem(write func() error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := write(); err != nil {
+		return err
+	}
+	s.pending++
+	if s.policy.Items > 0 && s.pending >= s.policy.Items {
+		s.flush()
+	} else if s.policy.Interval > 0 && s.timer == nil {
+		s.timer = time.AfterFunc(s.policy.Interval, s.flushByTimer)
+	}
+	return nil
+}
+
+// write call write with mutex held, it is not counted as item
+func (s *streamWriter) write(write func() error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return write()
+}
+
+func (s *streamWriter) flushByTimer() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.ended {
+		s.timer = nil
+		s.flush()
+	}
+}
+
+// flush send the written items, mutex must be held
+func (s *streamWriter) flush() {
+	if s.beforeFlush != nil {
+		s.beforeFlush()
+	}
+	s.w.Flush()
+	s.pending = 0
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// end flush the response and stop the timer, nothing is written by timer after it returns
+func (s *streamWriter) end() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.flush()
+	s.ended = true
+}
+
+// StreamJSONArray write items produced by iter as a json array incrementally
+func (ctx *HttpContext) StreamJSONArray(iter StreamIterator) error {
+	s := newStreamWriter(ctx, MIMEApplicationJSONCharsetUTF8)
+	defer s.end()
+	if _, err := s.Write([]byte("[")); err != nil {
+		return err
+	}
+	first := true
+	err := iter(func(item interface{}) error {
+		if err := s.done(); err != nil {
+			return err
+		}
+		b, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		return s.writeItem(func() error {
+			if !first {
+				if _, err := s.Write([]byte(",")); err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err := s.Write(b)
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return s.write(func() error {
+		_, err := s.Write([]byte("]"))
+		return err
+	})
+}
+
+// StreamNDJSON write items received from ch as newline delimited json until ch is closed
+func (ctx *HttpContext) StreamNDJSON(ch <-chan interface{}) error {
+	s := newStreamWriter(ctx, MIMEApplicationNDJSON)
+	defer s.end()
+	for {
+		select {
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		case item, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			b, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if err = s.writeItem(func() error {
+				_, err := s.Write(append(b, '\n'))
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StreamCSV write header and rows received from rows as csv until rows is closed, header is skipped if empty
+func (ctx *HttpContext) StreamCSV(header []string, rows <-chan []string) error {
+	s := newStreamWriter(ctx, MIMETextCSVCharsetUTF8)
+	cw := csv.NewWriter(s)
+	s.beforeFlush = cw.Flush
+	defer s.end()
+	if len(header) > 0 {
+		if err := s.write(func() error {
+			return cw.Write(header)
+		}); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Context().Done():
+			return ctx.Context().Err()
+		case row, ok := <-rows:
+			if !ok {
+				s.end()
+				return s.write(cw.Error)
+			}
+			if err := s.writeItem(func() error {
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+				// error of previous flush
+				return cw.Error()
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
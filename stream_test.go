@@ -0,0 +1,123 @@
+package dotweb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestContext_Stream(t *testing.T) {
+	app := New()
+	app.HttpServer.SetStreamFlushPolicy(StreamFlushPolicy{Items: 1})
+	app.HttpServer.GET("/array", func(ctx Context) error {
+		return ctx.StreamJSONArray(func(yield func(item interface{}) error) error {
+			for i := 1; i <= 3; i++ {
+				if err := yield(map[string]int{"id": i}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	app.HttpServer.GET("/ndjson", func(ctx Context) error {
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			ch <- "a"
+			ch <- 1
+		}()
+		return ctx.StreamNDJSON(ch)
+	})
+	app.HttpServer.GET("/csv", func(ctx Context) error {
+		rows := make(chan []string, 2)
+		rows <- []string{"1", "dot,web"}
+		rows <- []string{"2", "go"}
+		close(rows)
+		return ctx.StreamCSV([]string{"id", "name"}, rows)
+	})
+	app.HttpServer.GET("/endless", func(ctx Context) error {
+		ch := make(chan interface{}, 1)
+		ch <- "first"
+		return ctx.StreamNDJSON(ch)
+	}).Timeout(20 * time.Millisecond)
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/array", nil)
+	test.Equal(t, MIMEApplicationJSONCharsetUTF8, w.Header().Get(HeaderContentType))
+	test.Equal(t, `[{"id":1},{"id":2},{"id":3}]`, w.Body.String())
+	test.Equal(t, true, w.Flushed)
+
+	w = doTestRequest(app, "GET", "/ndjson", nil)
+	test.Equal(t, MIMEApplicationNDJSON, w.Header().Get(HeaderContentType))
+	test.Equal(t, "\"a\"\n1\n", w.Body.String())
+
+	w = doTestRequest(app, "GET", "/csv", nil)
+	test.Equal(t, MIMETextCSVCharsetUTF8, w.Header().Get(HeaderContentType))
+	test.Equal(t, "id,name\n1,\"dot,web\"\n2,go\n", w.Body.String())
+
+	// stop when context is done
+	w = doTestRequest(app, "GET", "/endless", nil)
+	test.Equal(t, true, strings.HasPrefix(w.Body.String(), "\"first\"\n"))
+}
+
+func TestContext_StreamInterval(t *testing.T) {
+	app := New()
+	app.HttpServer.SetStreamFlushPolicy(StreamFlushPolicy{Interval: 20 * time.Millisecond})
+	received := make(chan struct{})
+	app.HttpServer.GET("/ndjson", func(ctx Context) error {
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			ch <- "first"
+			// no more item until client received the first one
+			<-received
+			ch <- "second"
+		}()
+		return ctx.StreamNDJSON(ch)
+	})
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/ndjson")
+	test.Nil(t, err)
+	defer res.Body.Close()
+	r := bufio.NewReader(res.Body)
+	line, err := r.ReadString('\n')
+	test.Nil(t, err)
+	test.Equal(t, "\"first\"\n", line)
+	close(received)
+	rest, err := io.ReadAll(r)
+	test.Nil(t, err)
+	test.Equal(t, "\"second\"\n", string(rest))
+}
+
+func TestContext_StreamGzip(t *testing.T) {
+	app := New()
+	app.HttpServer.SetEnabledGzip(true)
+	app.HttpServer.SetStreamFlushPolicy(StreamFlushPolicy{Items: 1})
+	app.HttpServer.GET("/ndjson", func(ctx Context) error {
+		ch := make(chan interface{}, 2)
+		ch <- 1
+		ch <- 2
+		close(ch)
+		return ctx.StreamNDJSON(ch)
+	})
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/ndjson", nil)
+	test.Equal(t, gzipScheme, w.Header().Get(HeaderContentEncoding))
+	test.Equal(t, true, w.Flushed)
+	gr, err := gzip.NewReader(w.Body)
+	test.Nil(t, err)
+	body, err := io.ReadAll(gr)
+	test.Nil(t, err)
+	test.Equal(t, "1\n2\n", string(body))
+	test.Equal(t, http.StatusOK, w.Code)
+}
@@ -5,7 +5,10 @@
 package dotweb
 
 import (
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
@@ -21,10 +24,15 @@ const maxParamCount uint8 = ^uint8(0)
 
 func countParams(path string) uint8 {
 	var n uint
-	for i := range []byte(path) {
+	for i := 0; i < len(path); i++ {
 		switch path[i] {
 		case ':', '*':
 			n++
+		case '<':
+			// skip param constraint, it may contain ':' or '*'
+			if end := constraintEnd(path, i); end > i {
+				i = end
+			}
 		}
 	}
 	return uint8(n)
@@ -53,6 +61,26 @@ type Node struct {
 	middlewares          []Middleware
 	handle               RouterHandle
 	priority             uint32
+	constraint           *paramConstraint
+	name                 string
+	method               string
+	router               *router
+	handlerName          string
+	isStatic             bool
+	isHijack             bool
+	isWebSocket          bool
+	isAuto               bool
+	group                *xGroup
+	version              string
+	variants             []*Node
+	isVersionHolder      bool
+	limit                *routeLimit
+	// methodNotAllowedHandler and optionsHandler are used for requests of the path with other methods
+	methodNotAllowedHandler StandardHandle
+	optionsHandler          StandardHandle
+	// detached is set on the copy returned by route registration, it is never in the tree,
+	// so it is kept in sync with the route in tree when changed by Use, Name etc.
+	detached bool
 }
 
 // Use registers a middleware
@@ -60,6 +88,16 @@ func (n *Node) Use(m ...Middleware) *Node {
 	if len(m) <= 0 {
 		return n
 	}
+	return n.update(func(route *Node) {
+		route.use(m...)
+	})
+}
+
+// use append middlewares to the node
+func (n *Node) use(m ...Middleware) {
+	if len(m) <= 0 {
+		return
+	}
 	step := len(n.middlewares) - 1
 	for i := range m {
 		if m[i] != nil {
@@ -70,7 +108,6 @@ func (n *Node) Use(m ...Middleware) *Node {
 			step++
 		}
 	}
-	return n
 }
 
 // AppMiddlewares return AppMiddlewares
@@ -93,10 +130,187 @@ func (n *Node) Path() string {
 	return n.fullPath
 }
 
+// Name set the route name, used to build url by Router.URL or Context.URLFor
+func (n *Node) Name(name string) *Node {
+	if n.router != nil {
+		n.router.registerName(name, n.fullPath)
+	}
+	return n.update(func(route *Node) {
+		route.name = name
+	})
+}
+
+// RouteName return the route name set by Name
+func (n *Node) RouteName() string {
+	return n.name
+}
+
+// SetMethodNotAllowedHandle set custom 405 handler for the path of route,
+// it is used before the handlers of group and app
+func (n *Node) SetMethodNotAllowedHandle(handler StandardHandle) *Node {
+	return n.update(func(route *Node) {
+		route.methodNotAllowedHandler = handler
+	})
+}
+
+// SetOptionsHandle set custom handler for automatic OPTIONS reply of the path of route,
+// it is used before the handler of group
+func (n *Node) SetOptionsHandle(handler StandardHandle) *Node {
+	return n.update(func(route *Node) {
+		route.optionsHandler = handler
+	})
+}
+
 func (n *Node) Node() *Node {
 	return n
 }
 
+// copyRoute copy route info and handle from src node
+func (n *Node) copyRoute(src *Node) {
+	n.fullPath = src.fullPath
+	n.hasExcludeMiddleware = src.hasExcludeMiddleware
+	n.appMiddlewares = src.appMiddlewares
+	n.groupMiddlewares = src.groupMiddlewares
+	n.middlewares = src.middlewares
+	n.handle = src.handle
+	n.name = src.name
+	n.method = src.method
+	n.router = src.router
+	n.handlerName = src.handlerName
+	n.isStatic = src.isStatic
+	n.isHijack = src.isHijack
+	n.isWebSocket = src.isWebSocket
+	n.isAuto = src.isAuto
+	n.group = src.group
+	n.version = src.version
+	n.variants = src.variants
+	n.isVersionHolder = src.isVersionHolder
+	n.limit = src.limit
+	n.methodNotAllowedHandler = src.methodNotAllowedHandler
+	n.optionsHandler = src.optionsHandler
+}
+
+// detach return a copy of the route which is not in the tree, route registration return it
+// instead of the tree node, as tree node may be copied or reused by other routes when tree changed
+func (n *Node) detach() *Node {
+	handle := &Node{detached: true}
+	handle.copyRoute(n)
+	return handle
+}
+
+// clearRoute clear route info and handle, used when node become a inner node
+func (n *Node) clearRoute() {
+	n.hasExcludeMiddleware = false
+	n.appMiddlewares = nil
+	n.groupMiddlewares = nil
+	n.middlewares = nil
+	n.handle = nil
+	n.name = ""
+	n.handlerName = ""
+	n.isStatic = false
+	n.isHijack = false
+	n.isWebSocket = false
+	n.isAuto = false
+	n.group = nil
+	n.version = ""
+	n.variants = nil
+	n.isVersionHolder = false
+	n.limit = nil
+	n.methodNotAllowedHandler = nil
+	n.optionsHandler = nil
+}
+
+// addVariant add a version variant of the route node, variants are not in the tree
+// and selected by selectVariant when the route node is matched
+func (n *Node) addVariant(path string, handle RouterHandle, version string) *Node {
+	for _, v := range n.variants {
+		if v.version == version {
+			panic("version '" + version + "' is already registered for path '" + path + "'")
+		}
+	}
+	variant := &Node{path: path, handle: handle, version: version}
+	// copy the slice, the old one may be used by in-flight lookups
+	n.variants = append(append([]*Node{}, n.variants...), variant)
+	return variant
+}
+
+// variantOf return the variant registered by group g
+func (n *Node) variantOf(g *xGroup) *Node {
+	if n == nil {
+		return nil
+	}
+	for _, v := range n.variants {
+		if v.group == g {
+			return v
+		}
+	}
+	return nil
+}
+
+// selectVariant return the version variant matched by request,
+// fall back to the variant of group's default version, then the route without version.
+// The matchers of all variants are called, so each of them adds its header to Vary
+func (n *Node) selectVariant(ctx Context) *Node {
+	var selected *Node
+	for _, v := range n.variants {
+		if v.group.versionMatcher(ctx) == v.version && selected == nil {
+			selected = v
+		}
+	}
+	if selected != nil {
+		return selected
+	}
+	for _, v := range n.variants {
+		if p := v.group.versionParent; p != nil && p.defaultVersion == v.version {
+			return v
+		}
+	}
+	if n.isVersionHolder {
+		return nil
+	}
+	return n
+}
+
+// update apply fn to the route which n is registered as, the route is found in current tree
+// by method and path, so the change is not lost when n is a stale copy left by tree rebuild.
+// When server is serving, fn is applied to a copy of the route which is published after change,
+// so in-flight requests never see a half changed route
+func (n *Node) update(fn func(route *Node)) *Node {
+	if n.router == nil || !n.router.updateRoute(n, fn) {
+		fn(n)
+	}
+	return n
+}
+
+// rebuild create a new tree with all routes in n except the ones skip returns true,
+// the routes are copied so n is never modified and can still be used by in-flight lookups
+func (n *Node) rebuild(skip func(route *Node) bool) *Node {
+	root := new(Node)
+	if n == nil {
+		return root
+	}
+	n.walk(func(route *Node) {
+		if route.handle == nil || (skip != nil && skip(route)) {
+			return
+		}
+		root.addRoute(route.fullPath, route.handle).copyRoute(route)
+	})
+	return root
+}
+
+// isEmpty check the tree has no route
+func (n *Node) isEmpty() bool {
+	return n.handle == nil && len(n.children) == 0
+}
+
+// walk call fn on the node and all its children
+func (n *Node) walk(fn func(n *Node)) {
+	fn(n)
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+}
+
 // Increments priority of the given child and reorders if necessary
 func (n *Node) incrementChildPrio(pos int) int {
 	cs := n.children
@@ -150,18 +364,14 @@ func (n *Node) addRoute(path string, handle RouterHandle, m ...Middleware) (outn
 			// Split edge
 			if i < len(n.path) {
 				child := Node{
-					path:             n.path[i:],
-					fullPath:         n.fullPath,
-					wildChild:        n.wildChild,
-					nType:            static,
-					indices:          n.indices,
-					children:         n.children,
-					handle:           n.handle,
-					priority:         n.priority - 1,
-					middlewares:      n.middlewares,
-					groupMiddlewares: n.groupMiddlewares,
-					appMiddlewares:   n.appMiddlewares,
+					path:      n.path[i:],
+					wildChild: n.wildChild,
+					nType:     static,
+					indices:   n.indices,
+					children:  n.children,
+					priority:  n.priority - 1,
 				}
+				child.copyRoute(n)
 
 				// Update maxParams (max of all children)
 				for i := range child.children {
@@ -174,11 +384,8 @@ func (n *Node) addRoute(path string, handle RouterHandle, m ...Middleware) (outn
 				// []byte for proper unicode char conversion, see #65
 				n.indices = string([]byte{n.path[i]})
 				n.path = path[:i]
-				n.handle = nil
 				n.wildChild = false
-				n.middlewares = nil
-				n.groupMiddlewares = nil
-				n.appMiddlewares = nil
+				n.clearRoute()
 			}
 
 			// Make new node a child of this node
@@ -186,35 +393,45 @@ func (n *Node) addRoute(path string, handle RouterHandle, m ...Middleware) (outn
 				path = path[i:]
 
 				if n.wildChild {
-					n = n.children[0]
-					n.priority++
+					// Check if the wildcard matches, e.g. :name but not :names
+					if child := n.findWildChild(path); child != nil {
+						n = child
+						n.priority++
+
+						// Update maxParams of the child node
+						if numParams > n.maxParams {
+							n.maxParams = numParams
+						}
+						numParams--
+						continue walk
+					}
 
-					// Update maxParams of the child node
-					if numParams > n.maxParams {
-						n.maxParams = numParams
+					// A param with a different constraint can live next to
+					// the existing params, e.g. :id<int> and :name
+					if path[0] == ':' && n.children[0].nType == param {
+						conflict := n.conflictParamChild(path)
+						if conflict == nil {
+							outnode = n.addParamChild(numParams, path, fullPath, handle, m...)
+							return
+						}
+						n = conflict
+					} else {
+						n = n.children[0]
 					}
-					numParams--
 
-					// Check if the wildcard matches
-					if len(path) >= len(n.path) && n.path == path[:len(n.path)] &&
-						// Check for longer wildcard, e.g. :name and :names
-						(len(n.path) >= len(path) || path[len(n.path)] == '/') {
-						continue walk
+					// Wildcard conflict
+					var pathSeg string
+					if n.nType == catchAll {
+						pathSeg = path
 					} else {
-						// Wildcard conflict
-						var pathSeg string
-						if n.nType == catchAll {
-							pathSeg = path
-						} else {
-							pathSeg = strings.SplitN(path, "/", 2)[0]
-						}
-						prefix := fullPath[:strings.Index(fullPath, pathSeg)] + n.path
-						panic("'" + pathSeg +
-							"' in new path '" + fullPath +
-							"' conflicts with existing wildcard '" + n.path +
-							"' in existing prefix '" + prefix +
-							"'")
+						pathSeg = strings.SplitN(path, "/", 2)[0]
 					}
+					prefix := fullPath[:strings.Index(fullPath, pathSeg)] + n.path
+					panic("'" + pathSeg +
+						"' in new path '" + fullPath +
+						"' conflicts with existing wildcard '" + n.path +
+						"' in existing prefix '" + prefix +
+						"'")
 				}
 
 				c := path[0]
@@ -254,7 +471,7 @@ func (n *Node) addRoute(path string, handle RouterHandle, m ...Middleware) (outn
 					panic("a handle is already registered for path '" + fullPath + "'")
 				}
 				n.handle = handle
-				n.Use(m...)
+				n.use(m...)
 				outnode = n
 			}
 			return
@@ -285,6 +502,18 @@ func (n *Node) insertChild(numParams uint8, path, fullPath string, handle Router
 			if c == '/' {
 				break
 			}
+			if c == '<' {
+				// param constraint, e.g. :id<int>, must close the segment
+				cEnd := constraintEnd(path, end)
+				if cEnd < 0 {
+					panic("unclosed param constraint in path '" + fullPath + "'")
+				}
+				end = cEnd + 1
+				if end < max && path[end] != '/' {
+					invalid = true
+				}
+				continue
+			}
 			if c == ':' || c == '*' {
 				invalid = true
 			}
@@ -298,7 +527,7 @@ func (n *Node) insertChild(numParams uint8, path, fullPath string, handle Router
 		}
 
 		// Check if the wildcard has a name
-		if end-i < 2 {
+		if end-i < 2 || path[i+1] == '<' {
 			panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
 		}
 
@@ -317,8 +546,9 @@ func (n *Node) insertChild(numParams uint8, path, fullPath string, handle Router
 			}
 
 			child := &Node{
-				nType:     param,
-				maxParams: numParams,
+				nType:      param,
+				maxParams:  numParams,
+				constraint: newParamConstraint(path[i:end], fullPath),
 			}
 			n.children = []*Node{child}
 			n.wildChild = true
@@ -331,6 +561,7 @@ func (n *Node) insertChild(numParams uint8, path, fullPath string, handle Router
 			if end < max {
 				n.path = path[offset:end]
 				offset = end
+				i = end - 1
 
 				child := &Node{
 					maxParams: numParams,
@@ -345,6 +576,10 @@ func (n *Node) insertChild(numParams uint8, path, fullPath string, handle Router
 				panic("catch-all routes are only allowed at the end of the path in path '" + fullPath + "'")
 			}
 
+			if strings.IndexByte(path[i:end], '<') >= 0 {
+				panic("catch-all routes do not support param constraints in path '" + fullPath + "'")
+			}
+
 			if len(n.path) > 0 && n.path[len(n.path)-1] == '/' {
 				panic("catch-all conflicts with existing handle for the path segment root in path '" + fullPath + "'")
 			}
@@ -379,17 +614,17 @@ func (n *Node) insertChild(numParams uint8, path, fullPath string, handle Router
 				handle:    handle,
 				priority:  1,
 			}
-			child.Use(m...)
+			child.use(m...)
 			n.children = []*Node{child}
 
-			return n
+			return child
 		}
 	}
 
 	// Insert remaining path part and handle to the leaf
 	n.path = path[offset:]
 	n.handle = handle
-	n.Use(m...)
+	n.use(m...)
 	return n
 }
 
@@ -419,8 +654,7 @@ walk: // outer loop for walking the tree
 				}
 
 				// handle wildcard child
-				n = n.children[0]
-				switch n.nType {
+				switch n.children[0].nType {
 				case param:
 					// find param end (either '/' or path end)
 					end := 0
@@ -428,6 +662,11 @@ walk: // outer loop for walking the tree
 						end++
 					}
 
+					// prefer the param registered with this exact pattern
+					if n = n.findParamChild(path[:end]); n == nil {
+						return nil
+					}
+
 					// we need to go deeper!
 					if end < len(path) {
 						if len(n.children) > 0 {
@@ -443,7 +682,7 @@ walk: // outer loop for walking the tree
 					return n
 
 				case catchAll:
-					return n
+					return n.children[0]
 				default:
 					panic("invalid node type")
 				}
@@ -487,9 +726,7 @@ walk: // outer loop for walking the tree
 				}
 
 				// handle wildcard child
-				outnode = n.children[0]
-				n = outnode
-				switch n.nType {
+				switch n.children[0].nType {
 				case param:
 					// find param end (either '/' or path end)
 					end := 0
@@ -497,6 +734,19 @@ walk: // outer loop for walking the tree
 						end++
 					}
 
+					// params with different constraints share the segment, e.g. :id<int> and :name,
+					// the next one is tried if the rest of path is not matched
+					if len(n.children) > 1 {
+						return n.getParamValue(path, end, p)
+					}
+
+					// pick the param if its constraint accepts the value,
+					// if not the lookup fails like any other mismatch
+					if n = n.matchParamChild(path[:end]); n == nil {
+						return
+					}
+					outnode = n
+
 					// save param value
 					if p == nil {
 						// lazy allocation
@@ -504,7 +754,7 @@ walk: // outer loop for walking the tree
 					}
 					i := len(p)
 					p = p[:i+1] // expand slice within preallocated capacity
-					p[i].Key = n.path[1:]
+					p[i].Key = n.paramKey()
 					p[i].Value = path[:end]
 
 					// we need to go deeper!
@@ -532,6 +782,9 @@ walk: // outer loop for walking the tree
 					return
 
 				case catchAll:
+					outnode = n.children[0]
+					n = outnode
+
 					// save param value
 					if p == nil {
 						// lazy allocation
@@ -703,8 +956,7 @@ walk: // outer loop for walking the tree
 				return ciPath, (fixTrailingSlash && path == "/" && n.handle != nil)
 			}
 
-			n = n.children[0]
-			switch n.nType {
+			switch n.children[0].nType {
 			case param:
 				// find param end (either '/' or path end)
 				k := 0
@@ -712,6 +964,10 @@ walk: // outer loop for walking the tree
 					k++
 				}
 
+				if n = n.matchParamChild(path[:k]); n == nil {
+					return ciPath, false
+				}
+
 				// add param value to case insensitive path
 				ciPath = append(ciPath, path[:k]...)
 
@@ -789,3 +1045,245 @@ walk: // outer loop for walking the tree
 	}
 	return ciPath, false
 }
+
+// constraintEnd returns the index of the '>' closing the param constraint
+// which starts at path[start], or -1 if it is not closed
+func constraintEnd(path string, start int) int {
+	depth := 0
+	for i := start; i < len(path); i++ {
+		switch path[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// wildcardSegment returns the wildcard at the beginning of path,
+// including its param constraint
+func wildcardSegment(path string) string {
+	end := 0
+	for end < len(path) && path[end] != '/' {
+		if path[end] == '<' {
+			if cEnd := constraintEnd(path, end); cEnd > end {
+				end = cEnd
+			}
+		}
+		end++
+	}
+	return path[:end]
+}
+
+// constraintExpr returns the constraint of a wildcard, e.g. "int" for ":id<int>"
+func constraintExpr(wildcard string) string {
+	if i := strings.IndexByte(wildcard, '<'); i >= 0 && wildcard[len(wildcard)-1] == '>' {
+		return wildcard[i+1 : len(wildcard)-1]
+	}
+	return ""
+}
+
+// paramKey return the param name without constraint
+func (n *Node) paramKey() string {
+	key := n.path[1:]
+	if i := strings.IndexByte(key, '<'); i >= 0 {
+		key = key[:i]
+	}
+	return key
+}
+
+// findWildChild return the wildcard child which is registered with the
+// wildcard at the beginning of path
+func (n *Node) findWildChild(path string) *Node {
+	for _, child := range n.children {
+		if len(path) >= len(child.path) && child.path == path[:len(child.path)] &&
+			// Check for longer wildcard, e.g. :name and :names
+			(len(child.path) >= len(path) || path[len(child.path)] == '/') {
+			return child
+		}
+	}
+	return nil
+}
+
+// conflictParamChild return the param child which can't live next to the
+// param at the beginning of path, params conflict if they have the same
+// constraint (or both have none) but a different name
+func (n *Node) conflictParamChild(path string) *Node {
+	expr := constraintExpr(wildcardSegment(path))
+	for _, child := range n.children {
+		if constraintExpr(child.path) == expr {
+			return child
+		}
+	}
+	return nil
+}
+
+// addParamChild insert a new param child next to the existing params.
+// Constrained params are tried in registration order, the unconstrained
+// param (if any) is always tried last.
+func (n *Node) addParamChild(numParams uint8, path, fullPath string, handle RouterHandle, m ...Middleware) *Node {
+	holder := &Node{}
+	outnode := holder.insertChild(numParams, path, fullPath, handle, m...)
+	child := holder.children[0]
+
+	last := len(n.children) - 1
+	if child.constraint != nil && n.children[last].constraint == nil {
+		n.children = append(n.children[:last], child, n.children[last])
+	} else {
+		n.children = append(n.children, child)
+	}
+	return outnode
+}
+
+// getParamValue match path by the param children of n in order, path[:end] is the param value,
+// it backtracks to the next param child when the rest of path is not found under the previous one
+func (n *Node) getParamValue(path string, end int, p Params) (handle RouterHandle, ps Params, outnode *Node, tsr bool) {
+	value := path[:end]
+	for _, child := range n.children {
+		if child.constraint != nil && !child.constraint.match(value) {
+			continue
+		}
+		// copy params, the slice must not be shared by the tried children
+		params := make(Params, len(p)+1, len(p)+int(child.maxParams))
+		copy(params, p)
+		params[len(p)] = Param{Key: child.paramKey(), Value: value}
+
+		if end == len(path) {
+			if child.handle != nil {
+				return child.handle, params, child, false
+			}
+			if len(child.children) == 1 && child.children[0].path == "/" && child.children[0].handle != nil {
+				tsr = true
+			}
+			continue
+		}
+		if len(child.children) == 0 {
+			tsr = tsr || len(path) == end+1
+			continue
+		}
+		h, rest, out, t := child.children[0].getValue(path[end:])
+		if h != nil {
+			return h, append(params, rest...), out, false
+		}
+		tsr = tsr || t
+	}
+	return nil, nil, nil, tsr
+}
+
+// matchParamChild return the first param child which accepts the value
+func (n *Node) matchParamChild(value string) *Node {
+	for _, child := range n.children {
+		if child.constraint == nil || child.constraint.match(value) {
+			return child
+		}
+	}
+	return nil
+}
+
+// findParamChild return the param child registered with the given wildcard,
+// e.g. ":id<int>", otherwise the first param child which accepts the value
+func (n *Node) findParamChild(segment string) *Node {
+	for _, child := range n.children {
+		if child.path == segment {
+			return child
+		}
+	}
+	return n.matchParamChild(segment)
+}
+
+// paramConstraint is the constraint of a route param, e.g. /user/:id<int>
+// it is checked during tree lookup, so a value which does not match falls
+// through to the NotFound/MethodNotAllowed handling
+type paramConstraint struct {
+	expr  string
+	match func(value string) bool
+}
+
+var (
+	paramConstraintMutex = new(sync.RWMutex)
+	paramConstraintMap   = map[string]func(value string) bool{
+		"int":   isIntParam,
+		"uint":  isUintParam,
+		"alpha": isAlphaParam,
+		"alnum": isAlnumParam,
+		"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	}
+)
+
+// RegisterParamConstraint register a named route param constraint,
+// then it can be used in route path like /user/:name<username>
+// built-in constraints are int, uint, alpha, alnum and uuid,
+// any other constraint is compiled as a regular expression which must match the whole value
+// it must be called before the routes which use it are registered
+func RegisterParamConstraint(name string, match func(value string) bool) {
+	paramConstraintMutex.Lock()
+	paramConstraintMap[name] = match
+	paramConstraintMutex.Unlock()
+}
+
+// newParamConstraint create the constraint of the given wildcard, e.g. ":id<int>"
+// it returns nil if the wildcard has no constraint
+func newParamConstraint(wildcard, fullPath string) *paramConstraint {
+	expr := constraintExpr(wildcard)
+	if expr == "" {
+		if strings.IndexByte(wildcard, '<') >= 0 {
+			panic("empty param constraint in path '" + fullPath + "'")
+		}
+		return nil
+	}
+	if strings.IndexByte(expr, '/') >= 0 {
+		panic("param constraint must not contain '/' in path '" + fullPath + "'")
+	}
+
+	paramConstraintMutex.RLock()
+	match, exists := paramConstraintMap[expr]
+	paramConstraintMutex.RUnlock()
+	if !exists {
+		reg, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			panic("invalid param constraint '" + expr + "' in path '" + fullPath + "': " + err.Error())
+		}
+		match = reg.MatchString
+	}
+	return &paramConstraint{expr: expr, match: match}
+}
+
+func isIntParam(value string) bool {
+	_, err := strconv.ParseInt(value, 10, 64)
+	return err == nil
+}
+
+func isUintParam(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
+
+func isAlphaParam(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnumParam(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package dotweb
+
+import (
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func newTestTreeHandle(name string, hits *string) RouterHandle {
+	return func(ctx Context) {
+		*hits = name
+	}
+}
+
+func TestTree_ParamConstraint(t *testing.T) {
+	var hit string
+	tree := &Node{}
+	tree.addRoute("/user/:id<int>", newTestTreeHandle("int", &hit))
+	tree.addRoute("/user/:name", newTestTreeHandle("name", &hit))
+	tree.addRoute("/file/:name<[a-z0-9_-]+>/raw", newTestTreeHandle("file", &hit))
+	tree.addRoute("/v/:ver<uuid>", newTestTreeHandle("uuid", &hit))
+
+	tests := []struct {
+		path  string
+		found bool
+		hit   string
+		key   string
+		value string
+	}{
+		{"/user/123", true, "int", "id", "123"},
+		{"/user/abc", true, "name", "name", "abc"},
+		{"/file/a_b-1/raw", true, "file", "name", "a_b-1"},
+		{"/file/A.txt/raw", false, "", "", ""},
+		{"/v/6ba7b810-9dad-11d1-80b4-00c04fd430c8", true, "uuid", "ver", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{"/v/1.0", false, "", "", ""},
+	}
+	for _, tt := range tests {
+		hit = ""
+		handle, ps, _, _ := tree.getValue(tt.path)
+		if !tt.found {
+			test.Nil(t, handle)
+			continue
+		}
+		test.NotNil(t, handle)
+		handle(nil)
+		test.Equal(t, tt.hit, hit)
+		test.Equal(t, tt.value, ps.ByName(tt.key))
+	}
+}
+
+func TestTree_ParamConstraintGetNode(t *testing.T) {
+	var hit string
+	tree := &Node{}
+	intNode := tree.addRoute("/user/:id<int>", newTestTreeHandle("int", &hit))
+	nameNode := tree.addRoute("/user/:name", newTestTreeHandle("name", &hit))
+
+	test.Equal(t, intNode, tree.getNode("/user/:id<int>"))
+	test.Equal(t, nameNode, tree.getNode("/user/:name"))
+	test.Equal(t, intNode, tree.getNode("/user/1"))
+}
+
+func TestTree_ParamConstraintConflict(t *testing.T) {
+	var hit string
+	conflicts := [][]string{
+		{"/user/:id", "/user/:name"},
+		{"/user/:id<int>", "/user/:num<int>"},
+		{"/src/*filepath", "/src/:id<int>"},
+		{"/user/:id<[0-9]+", ""},
+		{"/user/:id<[0-9/]+>", ""},
+		{"/user/:<int>", ""},
+		{"/user/:id<(>", ""},
+	}
+	for _, paths := range conflicts {
+		tree := &Node{}
+		recv := catchPanic(func() {
+			for _, path := range paths {
+				if path != "" {
+					tree.addRoute(path, newTestTreeHandle(path, &hit))
+				}
+			}
+		})
+		if recv == nil {
+			t.Errorf("no panic for conflicting routes %v", paths)
+		}
+	}
+}
+
+func catchPanic(f func()) (recv interface{}) {
+	defer func() {
+		recv = recover()
+	}()
+	f()
+	return
+}
@@ -0,0 +1,385 @@
+package dotweb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const validateTagName = "validate"
+
+var emailRegexp = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+// ErrInvalidValidateRule is returned by DefaultValidator.Validate when validate tag has unknown rule or invalid param
+var ErrInvalidValidateRule = errors.New("invalid validate rule")
+
+// ValidationMessagesEN is the english message templates of built-in rules,
+// {field} and {param} are replaced with field path and rule param
+var ValidationMessagesEN = map[string]string{
+	"required": "{field} is required",
+	"min":      "{field} must be at least {param}",
+	"max":      "{field} must be at most {param}",
+	"len":      "{field} length must be {param}",
+	"email":    "{field} must be a valid email address",
+	"oneof":    "{field} must be one of [{param}]",
+	"":         "{field} is invalid",
+}
+
+// ValidationMessagesZH is the chinese message templates of built-in rules
+var ValidationMessagesZH = map[string]string{
+	"required": "{field}不能为空",
+	"min":      "{field}最小为{param}",
+	"max":      "{field}最大为{param}",
+	"len":      "{field}长度必须为{param}",
+	"email":    "{field}必须是有效的邮箱地址",
+	"oneof":    "{field}必须是[{param}]中的一个",
+	"":         "{field}格式不正确",
+}
+
+type (
+	// ValidationError describe a field which does not pass the validate rule
+	ValidationError struct {
+		// Field is the path of field, e.g. "Name", "Address.City", "Items[0].Name", "Tags[key]"
+		Field   string
+		Tag     string
+		Param   string
+		Value   interface{}
+		Message string
+	}
+
+	// ValidationErrors is returned by DefaultValidator.Validate when any field is invalid
+	ValidationErrors []*ValidationError
+
+	// ValidateRule check the field value with rule param, return false if invalid
+	ValidateRule func(v reflect.Value, param string) bool
+
+	// ValidationTranslator create the message of ValidationError
+	ValidationTranslator func(e *ValidationError) string
+
+	// validateRuleError is panicked by rule with invalid tag, and returned by Validate as error
+	validateRuleError struct {
+		msg string
+	}
+
+	// DefaultValidator is the built-in Validator driven by struct tag,
+	// e.g. `validate:"required,min=3,max=64,email,oneof=a b"`,
+	// nested structs, slices and maps are validated recursively.
+	DefaultValidator struct {
+		rules      map[string]ValidateRule
+		translator ValidationTranslator
+		mutex      *sync.RWMutex
+	}
+)
+
+// Error return the message of validation error
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *validateRuleError) Error() string {
+	return ErrInvalidValidateRule.Error() + ": " + e.msg
+}
+
+func (e *validateRuleError) Unwrap() error {
+	return ErrInvalidValidateRule
+}
+
+// Error return all messages joined by "; "
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Translate reset messages with translator, used to reply localized messages per request
+func (errs ValidationErrors) Translate(translator ValidationTranslator) ValidationErrors {
+	for _, e := range errs {
+		e.Message = translator(e)
+	}
+	return errs
+}
+
+// NewValidationTranslator return translator which use message templates keyed by rule name,
+// template with empty key is used for rule has no template
+func NewValidationTranslator(messages map[string]string) ValidationTranslator {
+	return func(e *ValidationError) string {
+		msg, exists := messages[e.Tag]
+		if !exists {
+			msg = messages[""]
+		}
+		return strings.NewReplacer("{field}", e.Field, "{param}", e.Param).Replace(msg)
+	}
+}
+
+// NewValidator create DefaultValidator with built-in rules and english messages
+func NewValidator() *DefaultValidator {
+	return &DefaultValidator{
+		rules: map[string]ValidateRule{
+			"required": validateRequired,
+			"min":      validateMin,
+			"max":      validateMax,
+			"len":      validateLen,
+			"email":    validateEmail,
+			"oneof":    validateOneOf,
+		},
+		translator: NewValidationTranslator(ValidationMessagesEN),
+		mutex:      new(sync.RWMutex),
+	}
+}
+
+// RegisterRule register custom rule, the rule with same name is replaced
+func (v *DefaultValidator) RegisterRule(name string, rule ValidateRule) *DefaultValidator {
+	v.mutex.Lock()
+	v.rules[name] = rule
+	v.mutex.Unlock()
+	return v
+}
+
+// SetTranslator set the translator used to create messages
+func (v *DefaultValidator) SetTranslator(translator ValidationTranslator) *DefaultValidator {
+	v.mutex.Lock()
+	v.translator = translator
+	v.mutex.Unlock()
+	return v
+}
+
+// Validate validate i by validate tag, return ValidationErrors if any field is invalid,
+// or error wraps ErrInvalidValidateRule if tag has unknown rule or invalid param
+func (v *DefaultValidator) Validate(i interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ruleErr, ok := r.(*validateRuleError)
+			if !ok {
+				panic(r)
+			}
+			err = ruleErr
+		}
+	}()
+	var errs ValidationErrors
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	v.validateValue("", reflect.ValueOf(i), &errs, make(map[visitedPointer]struct{}))
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// visitedPointer identify a pointer by address and type, so cyclic values are validated once
+type visitedPointer struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// validateValue walk into struct fields, slice and map elements
+func (v *DefaultValidator) validateValue(path string, val reflect.Value, errs *ValidationErrors, visited map[visitedPointer]struct{}) {
+	for val.IsValid() && (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) {
+		if val.IsNil() {
+			return
+		}
+		if val.Kind() == reflect.Ptr {
+			key := visitedPointer{ptr: val.Pointer(), typ: val.Type()}
+			if _, exists := visited[key]; exists {
+				return
+			}
+			visited[key] = struct{}{}
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return
+	}
+	switch val.Kind() {
+	case reflect.Struct:
+		typ := val.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag := field.Tag.Get(validateTagName)
+			if tag == "-" {
+				continue
+			}
+			fieldPath := path
+			if !field.Anonymous {
+				fieldPath = joinFieldPath(path, field.Name)
+			}
+			if tag != "" {
+				v.validateField(fieldPath, val.Field(i), tag, errs)
+			}
+			v.validateValue(fieldPath, val.Field(i), errs, visited)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			v.validateValue(path+"["+strconv.Itoa(i)+"]", val.Index(i), errs, visited)
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			v.validateValue(path+"["+fmt.Sprint(iter.Key().Interface())+"]", iter.Value(), errs, visited)
+		}
+	}
+}
+
+// validateField check field with rules in tag
+func (v *DefaultValidator) validateField(path string, val reflect.Value, tag string, errs *ValidationErrors) {
+	for _, item := range strings.Split(tag, ",") {
+		name, param := item, ""
+		if index := strings.Index(item, "="); index >= 0 {
+			name, param = item[:index], item[index+1:]
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "omitempty" {
+			if isEmptyValue(val) {
+				return
+			}
+			continue
+		}
+		rule, exists := v.rules[name]
+		if !exists {
+			panic(&validateRuleError{msg: "rule '" + name + "' of field '" + path + "' is not registered"})
+		}
+		if !checkRule(rule, val, param, path) {
+			e := &ValidationError{Field: path, Tag: name, Param: param}
+			if val.CanInterface() {
+				e.Value = val.Interface()
+			}
+			e.Message = v.translator(e)
+			*errs = append(*errs, e)
+			// report one error per field
+			return
+		}
+	}
+}
+
+// checkRule call rule, the field path is added to validateRuleError panicked by rule
+func checkRule(rule ValidateRule, val reflect.Value, param, path string) bool {
+	defer func() {
+		if r := recover(); r != nil {
+			if ruleErr, ok := r.(*validateRuleError); ok {
+				ruleErr.msg += " in field '" + path + "'"
+			}
+			panic(r)
+		}
+	}()
+	return rule(val, param)
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// indirectValue return the value pointer or interface point to
+func indirectValue(val reflect.Value) reflect.Value {
+	for val.IsValid() && (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) {
+		if val.IsNil() {
+			return reflect.Value{}
+		}
+		val = val.Elem()
+	}
+	return val
+}
+
+func isEmptyValue(val reflect.Value) bool {
+	if !val.IsValid() {
+		return true
+	}
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return val.Len() == 0
+	}
+	return val.IsZero()
+}
+
+func validateRequired(val reflect.Value, _ string) bool {
+	return !isEmptyValue(val)
+}
+
+// compareSize compare the size of value with param, size is length for string, slice and map,
+// or the value for numbers. ok is false if the value is nil, the rule is invalid on other kinds like struct and bool
+func compareSize(val reflect.Value, param string) (cmp int, ok bool) {
+	val = indirectValue(val)
+	if !val.IsValid() {
+		return 0, false
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(&validateRuleError{msg: "param '" + param + "' must be number"})
+	}
+	var size float64
+	switch val.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(val.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		size = float64(val.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		size = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = val.Float()
+	default:
+		panic(&validateRuleError{msg: "size rule can not be used on kind '" + val.Kind().String() + "'"})
+	}
+	switch {
+	case size < limit:
+		return -1, true
+	case size > limit:
+		return 1, true
+	}
+	return 0, true
+}
+
+func validateMin(val reflect.Value, param string) bool {
+	cmp, ok := compareSize(val, param)
+	return !ok || cmp >= 0
+}
+
+func validateMax(val reflect.Value, param string) bool {
+	cmp, ok := compareSize(val, param)
+	return !ok || cmp <= 0
+}
+
+func validateLen(val reflect.Value, param string) bool {
+	cmp, ok := compareSize(val, param)
+	return !ok || cmp == 0
+}
+
+func validateEmail(val reflect.Value, _ string) bool {
+	val = indirectValue(val)
+	if !val.IsValid() {
+		return true
+	}
+	if val.Kind() != reflect.String {
+		return false
+	}
+	return emailRegexp.MatchString(val.String())
+}
+
+func validateOneOf(val reflect.Value, param string) bool {
+	val = indirectValue(val)
+	if !val.IsValid() {
+		return true
+	}
+	s := fmt.Sprint(val.Interface())
+	for _, option := range strings.Fields(param) {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
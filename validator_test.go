@@ -0,0 +1,128 @@
+package dotweb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+type testValidateAddress struct {
+	City string `validate:"required"`
+}
+
+type testValidateUser struct {
+	Name      string `validate:"required,min=3,max=8"`
+	Email     string `validate:"omitempty,email"`
+	Role      string `validate:"oneof=admin user"`
+	Age       int    `validate:"min=18"`
+	Address   *testValidateAddress
+	Addresses []testValidateAddress `validate:"max=2"`
+	Tags      map[string]testValidateAddress
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v := NewValidator()
+	user := &testValidateUser{Name: "dotweb", Role: "admin", Age: 18}
+	test.Nil(t, v.Validate(user))
+
+	user = &testValidateUser{
+		Name:      "go",
+		Email:     "none",
+		Role:      "guest",
+		Age:       3,
+		Address:   &testValidateAddress{},
+		Addresses: []testValidateAddress{{City: "a"}, {}},
+		Tags:      map[string]testValidateAddress{"home": {}},
+	}
+	err := v.Validate(user)
+	errs, ok := err.(ValidationErrors)
+	test.Equal(t, true, ok)
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field+":"+e.Tag)
+	}
+	test.Equal(t, []string{"Name:min", "Email:email", "Role:oneof", "Age:min",
+		"Address.City:required", "Addresses[1].City:required", "Tags[home].City:required"}, fields)
+	test.Equal(t, "Name must be at least 3", errs[0].Message)
+
+	errs.Translate(NewValidationTranslator(ValidationMessagesZH))
+	test.Equal(t, "Name最小为3", errs[0].Message)
+
+	v.RegisterRule("even", func(val reflect.Value, param string) bool {
+		return val.Int()%2 == 0
+	})
+	type evenStruct struct {
+		N int `validate:"even"`
+	}
+	test.Equal(t, "N is invalid", v.Validate(evenStruct{N: 3}).Error())
+}
+
+type testValidateNode struct {
+	Name string `validate:"required"`
+	Next *testValidateNode
+}
+
+func TestValidator_InvalidRule(t *testing.T) {
+	v := NewValidator()
+	err := v.Validate(&struct {
+		Name string `validate:"requird"`
+	}{})
+	test.Equal(t, true, errors.Is(err, ErrInvalidValidateRule))
+	test.Equal(t, "invalid validate rule: rule 'requird' of field 'Name' is not registered", err.Error())
+
+	err = v.Validate(&struct {
+		Name string `validate:"min=a"`
+	}{Name: "dotweb"})
+	test.Equal(t, "invalid validate rule: param 'a' must be number in field 'Name'", err.Error())
+
+	err = v.Validate(&struct {
+		Enabled bool `validate:"min=1"`
+	}{})
+	test.Equal(t, "invalid validate rule: size rule can not be used on kind 'bool' in field 'Enabled'", err.Error())
+	err = v.Validate(&struct {
+		Address testValidateAddress `validate:"len=1"`
+	}{Address: testValidateAddress{City: "x"}})
+	test.Equal(t, true, errors.Is(err, ErrInvalidValidateRule))
+
+	// cyclic value is validated once
+	node := &testValidateNode{}
+	node.Next = &testValidateNode{Name: "b", Next: node}
+	err = v.Validate(node)
+	test.Equal(t, "Name is required", err.Error())
+}
+
+func TestContext_BindValidate(t *testing.T) {
+	app := New()
+	// validator is not registered by default
+	test.Nil(t, app.HttpServer.Validator)
+	app.HttpServer.Validator = NewValidator()
+	app.HttpServer.SetEnabledBindValidate(true)
+	app.HttpServer.POST("/user", func(ctx Context) error {
+		user := &testValidateUser{}
+		if err := ctx.Bind(user); err != nil {
+			return ctx.WriteStringC(http.StatusBadRequest, err.Error())
+		}
+		return ctx.WriteString(user.Name)
+	})
+	prepareTestApp(app)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/user", strings.NewReader(body))
+		req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		app.HttpServer.ServeHTTP(w, req)
+		return w
+	}
+	w := post(`{"Name":"dotweb","Role":"user","Age":20}`)
+	test.Equal(t, http.StatusOK, w.Code)
+	test.Equal(t, "dotweb", w.Body.String())
+
+	w = post(`{"Name":"dotweb","Role":"user","Age":10}`)
+	test.Equal(t, http.StatusBadRequest, w.Code)
+	test.Equal(t, "Age must be at least 18", w.Body.String())
+}
@@ -0,0 +1,40 @@
+package dotweb
+
+import "strings"
+
+// VersionMatcher return the api version requested by ctx, empty if not specified.
+// It is called for every request of route which has version variants, and should add the request header
+// it reads to response Vary like the built-in matchers, so caches do not mix up the versions
+type VersionMatcher func(ctx Context) string
+
+// HeaderVersionMatcher return VersionMatcher which read version from request header
+// simple demo:HeaderVersionMatcher("API-Version") => API-Version: v2
+func HeaderVersionMatcher(header string) VersionMatcher {
+	return func(ctx Context) string {
+		addVary(ctx.Response().Header(), header)
+		return strings.TrimSpace(ctx.Request().Header.Get(header))
+	}
+}
+
+// AcceptVersionMatcher return VersionMatcher which read version from vendor media type in Accept header
+// simple demo:AcceptVersionMatcher("x") => Accept: application/vnd.x.v2+json
+func AcceptVersionMatcher(vendor string) VersionMatcher {
+	prefix := "application/vnd." + vendor + "."
+	return func(ctx Context) string {
+		addVary(ctx.Response().Header(), HeaderAccept)
+		for _, mediaRange := range strings.Split(ctx.Request().Header.Get(HeaderAccept), ",") {
+			mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+			if !strings.HasPrefix(mediaType, prefix) {
+				continue
+			}
+			version := mediaType[len(prefix):]
+			if i := strings.IndexByte(version, '+'); i >= 0 {
+				version = version[:i]
+			}
+			if version != "" {
+				return version
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,99 @@
+package dotweb
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestGroup_Version(t *testing.T) {
+	app := New()
+	api := app.HttpServer.Group("/api")
+	api.GET("/user", func(ctx Context) error {
+		return ctx.WriteString("v1")
+	})
+	v2 := api.Version("v2", AcceptVersionMatcher("x"))
+	v2.GET("/user", func(ctx Context) error {
+		return ctx.WriteString("v2")
+	}).Use(&testHeaderMiddleware{})
+	v3 := api.Version("v3", HeaderVersionMatcher("API-Version"))
+	v3.GET("/user", func(ctx Context) error {
+		return ctx.WriteString("v3")
+	})
+	v3.GET("/order", func(ctx Context) error {
+		return ctx.WriteString("v3 order")
+	})
+	prepareTestApp(app)
+
+	w := doTestRequest(app, "GET", "/api/user", nil)
+	test.Equal(t, "v1", w.Body.String())
+
+	w = doTestRequest(app, "GET", "/api/user", http.Header{"Accept": []string{"text/html, application/vnd.x.v2+json;q=0.9"}})
+	test.Equal(t, "v2", w.Body.String())
+	test.Equal(t, "1", w.Header().Get("X-Test-Middleware"))
+
+	w = doTestRequest(app, "GET", "/api/user", http.Header{"Api-Version": []string{"v3"}})
+	test.Equal(t, "v3", w.Body.String())
+
+	// version only route
+	w = doTestRequest(app, "GET", "/api/order", http.Header{"Api-Version": []string{"v3"}})
+	test.Equal(t, "v3 order", w.Body.String())
+	w = doTestRequest(app, "GET", "/api/order", nil)
+	test.Equal(t, http.StatusNotFound, w.Code)
+
+	// fall back to default version
+	api.SetDefaultVersion("v3")
+	w = doTestRequest(app, "GET", "/api/order", nil)
+	test.Equal(t, "v3 order", w.Body.String())
+	w = doTestRequest(app, "GET", "/api/user", http.Header{"Api-Version": []string{"v9"}})
+	test.Equal(t, "v3", w.Body.String())
+
+	versions := ""
+	for _, route := range app.HttpServer.Router().Routes() {
+		if route.Method == "GET" && route.Path == "/api/user" {
+			versions += "[" + route.Version + "]"
+		}
+	}
+	test.Equal(t, "[][v2][v3]", versions)
+}
+
+func TestGroup_VersionVaryAndHEAD(t *testing.T) {
+	app := New()
+	app.HttpServer.SetEnabledAutoHEAD(true)
+	handler := func(version string) HttpHandle {
+		return func(ctx Context) error {
+			ctx.Response().Header().Set("X-Version", version)
+			return ctx.WriteString(version)
+		}
+	}
+	api := app.HttpServer.Group("/api")
+	// version only route is registered before the route without version
+	api.Version("v3", HeaderVersionMatcher("API-Version")).GET("/order", handler("v3"))
+	api.GET("/order", handler("v1"))
+	api.GET("/user", handler("v1"))
+	api.Version("v2", AcceptVersionMatcher("x")).GET("/user", handler("v2"))
+	api.Version("v3", HeaderVersionMatcher("API-Version")).GET("/user", handler("v3"))
+	api.GET("/plain", handler("v1"))
+	prepareTestApp(app)
+
+	// all headers read by matchers are in Vary
+	w := doTestRequest(app, "GET", "/api/user", http.Header{"Api-Version": {"v3"}})
+	test.Equal(t, "v3", w.Body.String())
+	test.Equal(t, []string{"Accept", "API-Version"}, w.Header().Values(HeaderVary))
+	w = doTestRequest(app, "GET", "/api/user", nil)
+	test.Equal(t, "v1", w.Body.String())
+	test.Equal(t, []string{"Accept", "API-Version"}, w.Header().Values(HeaderVary))
+	w = doTestRequest(app, "GET", "/api/plain", nil)
+	test.Equal(t, "", w.Header().Get(HeaderVary))
+
+	// version variants have auto HEAD even if the route without version has one
+	w = doTestRequest(app, "HEAD", "/api/user", http.Header{"Api-Version": {"v3"}})
+	test.Equal(t, "v3", w.Header().Get("X-Version"))
+	w = doTestRequest(app, "HEAD", "/api/user", http.Header{"Accept": {"application/vnd.x.v2+json"}})
+	test.Equal(t, "v2", w.Header().Get("X-Version"))
+	w = doTestRequest(app, "HEAD", "/api/order", nil)
+	test.Equal(t, "v1", w.Header().Get("X-Version"))
+	w = doTestRequest(app, "HEAD", "/api/order", http.Header{"Api-Version": {"v3"}})
+	test.Equal(t, "v3", w.Header().Get("X-Version"))
+}
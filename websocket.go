@@ -0,0 +1,628 @@
+package dotweb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// message types, defined in RFC 6455
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// close codes, defined in RFC 6455 section 7.4.1
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseAbnormalClosure    = 1006
+	CloseInvalidPayloadData = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseInternalServerErr  = 1011
+)
+
+const (
+	webSocketGUID                   = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	webSocketVersion                = "13"
+	webSocketDeflateExtension       = "permessage-deflate"
+	maxControlFramePayload          = 125
+	defaultWebSocketMaxMessageSize  = 1 << 20
+	defaultWebSocketPingInterval    = 30 * time.Second
+	defaultWebSocketPongWait        = 60 * time.Second
+	defaultWebSocketWriteWait       = 10 * time.Second
+	defaultWebSocketCompressionRate = flate.BestSpeed
+)
+
+// ErrWebSocketClosed is returned when read or write on a closed websocket
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+// the tail appended to compressed message, so flate reader can reach EOF, see RFC 7692 section 7.2.2
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+type (
+	// WebSocketConfig is the config of websocket routes
+	WebSocketConfig struct {
+		// MaxMessageSize limit the size of received message after decompression, zero means the default 1MB,
+		// the connection is closed with CloseMessageTooBig if exceeded
+		MaxMessageSize int64
+		// PingInterval is the interval of ping sent to client, zero disables ping
+		PingInterval time.Duration
+		// PongWait is the read deadline which is extended when any frame received, zero means no deadline
+		PongWait time.Duration
+		// WriteWait is the write deadline of each frame, zero means no deadline
+		WriteWait time.Duration
+		// Subprotocols are the supported subprotocols, the first one requested by client is selected
+		Subprotocols []string
+		// CheckOrigin return false to reject the handshake with 403,
+		// nil means the host of Origin header must be same as the request host
+		CheckOrigin func(req *http.Request) bool
+		// EnableCompression negotiate permessage-deflate extension with client
+		EnableCompression bool
+		// CompressionLevel is the flate level of sent messages, zero means flate.BestSpeed
+		CompressionLevel int
+	}
+
+	// WebSocket is a RFC 6455 websocket connection upgraded from request,
+	// write methods are safe to call from multiple goroutines, read methods must be called from one goroutine.
+	// The connection is closed when handler returns
+	WebSocket struct {
+		conn        net.Conn
+		br          *bufio.Reader
+		req         *http.Request
+		config      WebSocketConfig
+		subprotocol string
+		compress    bool
+		writeMutex  sync.Mutex
+		closeSent   bool
+		closed      atomic.Bool
+		stop        chan struct{}
+		stopped     sync.WaitGroup
+	}
+
+	// WebSocketCloseError is returned by read methods when the connection is closed by close frame,
+	// it is also returned when the connection is closed for protocol error or too big message
+	WebSocketCloseError struct {
+		Code int
+		Text string
+	}
+
+	// webSocketFrame is a frame received from client
+	webSocketFrame struct {
+		fin     bool
+		rsv1    bool
+		opcode  int
+		payload []byte
+	}
+)
+
+// DefaultWebSocketConfig return the default config, message size is limited to 1MB,
+// ping is sent every 30 seconds and read deadline is 60 seconds
+func DefaultWebSocketConfig() WebSocketConfig {
+	return WebSocketConfig{
+		MaxMessageSize: defaultWebSocketMaxMessageSize,
+		PingInterval:   defaultWebSocketPingInterval,
+		PongWait:       defaultWebSocketPongWait,
+		WriteWait:      defaultWebSocketWriteWait,
+	}
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// upgradeWebSocket do the opening handshake and hijack the connection,
+// the http status code is returned with error when the handshake is rejected
+func upgradeWebSocket(ctx Context, config WebSocketConfig) (*WebSocket, int, error) {
+	req := ctx.Request().Request
+	if req.Method != http.MethodGet {
+		return nil, http.StatusMethodNotAllowed, errors.New("websocket: handshake request method is not GET")
+	}
+	if !headerHasToken(req.Header, HeaderConnection, "upgrade") || !headerHasToken(req.Header, HeaderUpgrade, "websocket") {
+		return nil, http.StatusBadRequest, errors.New("websocket: not a websocket handshake")
+	}
+	if req.Header.Get(HeaderSecWebSocketVersion) != webSocketVersion {
+		ctx.Response().Header().Set(HeaderSecWebSocketVersion, webSocketVersion)
+		return nil, http.StatusUpgradeRequired, errors.New("websocket: unsupported version")
+	}
+	key := req.Header.Get(HeaderSecWebSocketKey)
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, http.StatusBadRequest, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(req) {
+		return nil, http.StatusForbidden, errors.New("websocket: origin not allowed")
+	}
+
+	res := ctx.Response()
+	if _, ok := res.Writer().(http.Hijacker); !ok {
+		return nil, http.StatusInternalServerError, errors.New("websocket: the web server does not support hijacking")
+	}
+	res.disableGzip()
+	conn, brw, err := res.Hijack()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	ws := &WebSocket{
+		conn:        conn,
+		br:          brw.Reader,
+		req:         req,
+		config:      config,
+		subprotocol: selectSubprotocol(req.Header, config.Subprotocols),
+		compress:    config.EnableCompression && acceptDeflate(req.Header),
+		stop:        make(chan struct{}),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString(HeaderSecWebSocketAccept + ": " + webSocketAcceptKey(key) + "\r\n")
+	if ws.subprotocol != "" {
+		buf.WriteString(HeaderSecWebSocketProtocol + ": " + ws.subprotocol + "\r\n")
+	}
+	if ws.compress {
+		buf.WriteString(HeaderSecWebSocketExtensions + ": " + webSocketDeflateExtension + "; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	// keep the headers set by middlewares, e.g. cookies
+	res.Header().WriteSubset(&buf, map[string]bool{
+		HeaderContentLength:   true,
+		HeaderContentType:     true,
+		HeaderContentEncoding: true,
+	})
+	buf.WriteString("\r\n")
+	// clear the deadlines set by http server
+	conn.SetDeadline(time.Time{})
+	if config.WriteWait > 0 {
+		conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+	}
+	if _, err = conn.Write(buf.Bytes()); err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+	res.Status = http.StatusSwitchingProtocols
+	res.committed = true
+
+	if config.PingInterval > 0 {
+		ws.stopped.Add(1)
+		go ws.pingLoop(config.PingInterval)
+	}
+	return ws, http.StatusSwitchingProtocols, nil
+}
+
+// Request get http request
+func (ws *WebSocket) Request() *http.Request {
+	return ws.req
+}
+
+// Subprotocol return the negotiated subprotocol, empty if no one selected
+func (ws *WebSocket) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// RemoteAddr return the remote network address
+func (ws *WebSocket) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SendMessage send text message
+func (ws *WebSocket) SendMessage(msg string) error {
+	return ws.WriteMessage(TextMessage, []byte(msg))
+}
+
+// ReadMessage read next text or binary message as string
+func (ws *WebSocket) ReadMessage() (string, error) {
+	_, data, err := ws.NextMessage()
+	return string(data), err
+}
+
+// WriteMessage send message, messageType must be TextMessage or BinaryMessage,
+// the message is compressed if permessage-deflate is negotiated
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("websocket: invalid message type " + strconv.Itoa(messageType))
+	}
+	if !ws.compress {
+		return ws.writeFrame(messageType, data, false)
+	}
+	compressed, err := deflateMessage(data, ws.config.CompressionLevel)
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(messageType, compressed, true)
+}
+
+// Ping send ping to client, the data must not be longer than 125 bytes
+func (ws *WebSocket) Ping(data []byte) error {
+	if len(data) > maxControlFramePayload {
+		return errors.New("websocket: control frame payload too long")
+	}
+	return ws.writeFrame(PingMessage, data, false)
+}
+
+// maxMessageSize return MaxMessageSize, or the default if it is not positive,
+// so the payload length sent by peer never allocates unlimited memory
+func (c WebSocketConfig) maxMessageSize() int64 {
+	if c.MaxMessageSize <= 0 {
+		return defaultWebSocketMaxMessageSize
+	}
+	return c.MaxMessageSize
+}
+
+// NextMessage read next text or binary message, ping is replied and pong extends the read deadline.
+// *WebSocketCloseError is returned when close frame received
+func (ws *WebSocket) NextMessage() (messageType int, data []byte, err error) {
+	compressed := false
+	for {
+		if ws.config.PongWait > 0 {
+			ws.conn.SetReadDeadline(time.Now().Add(ws.config.PongWait))
+		}
+		frame, err := ws.readFrame(ws.config.maxMessageSize() - int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frame.opcode {
+		case PingMessage:
+			if err = ws.writeFrame(PongMessage, frame.payload, false); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, ws.closeReceived(frame.payload)
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+			if frame.rsv1 {
+				return 0, nil, ws.fail(CloseProtocolError, "rsv1 set on continuation frame")
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "expect continuation frame")
+			}
+			if frame.rsv1 && !ws.compress {
+				return 0, nil, ws.fail(CloseProtocolError, "rsv1 set without compression")
+			}
+			messageType = frame.opcode
+			compressed = frame.rsv1
+		}
+		data = append(data, frame.payload...)
+		if frame.fin {
+			break
+		}
+	}
+	if compressed {
+		if data, err = ws.inflateMessage(data); err != nil {
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, ws.fail(CloseInvalidPayloadData, "invalid utf-8 text")
+	}
+	return messageType, data, nil
+}
+
+// Close send close frame with code and reason, then close the connection
+func (ws *WebSocket) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlFramePayload {
+		payload = payload[:maxControlFramePayload]
+	}
+	err := ws.writeFrame(CloseMessage, payload, false)
+	ws.closeConn()
+	if err == ErrWebSocketClosed {
+		return nil
+	}
+	return err
+}
+
+// IsClosed check the connection is closed
+func (ws *WebSocket) IsClosed() bool {
+	return ws.closed.Load()
+}
+
+// finish close the connection with code when handler returns, and wait ping loop exit
+func (ws *WebSocket) finish(code int) {
+	ws.Close(code, "")
+	ws.stopped.Wait()
+}
+
+// readFrame read a frame, the payload of data frame must not be longer than remain
+func (ws *WebSocket) readFrame(remain int64) (*webSocketFrame, error) {
+	var head [8]byte
+	if _, err := io.ReadFull(ws.br, head[:2]); err != nil {
+		return nil, ws.readErr(err)
+	}
+	frame := &webSocketFrame{
+		fin:    head[0]&0x80 != 0,
+		rsv1:   head[0]&0x40 != 0,
+		opcode: int(head[0] & 0x0f),
+	}
+	if head[0]&0x30 != 0 {
+		return nil, ws.fail(CloseProtocolError, "reserved bits set")
+	}
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(ws.br, head[:2]); err != nil {
+			return nil, ws.readErr(err)
+		}
+		length = int64(binary.BigEndian.Uint16(head[:2]))
+	case 127:
+		if _, err := io.ReadFull(ws.br, head[:8]); err != nil {
+			return nil, ws.readErr(err)
+		}
+		if head[0]&0x80 != 0 {
+			return nil, ws.fail(CloseProtocolError, "invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(head[:8]))
+	}
+	switch frame.opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+		if length > remain {
+			return nil, ws.fail(CloseMessageTooBig, "message too big")
+		}
+	case CloseMessage, PingMessage, PongMessage:
+		if !frame.fin || length > maxControlFramePayload || frame.rsv1 {
+			return nil, ws.fail(CloseProtocolError, "invalid control frame")
+		}
+	default:
+		return nil, ws.fail(CloseProtocolError, "unknown opcode "+strconv.Itoa(frame.opcode))
+	}
+	// client must mask all frames
+	if !masked {
+		return nil, ws.fail(CloseProtocolError, "frame is not masked")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return nil, ws.readErr(err)
+	}
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.br, frame.payload); err != nil {
+		return nil, ws.readErr(err)
+	}
+	for i := range frame.payload {
+		frame.payload[i] ^= mask[i%4]
+	}
+	return frame, nil
+}
+
+// writeFrame write a frame with write deadline, no frame can be written after close frame
+func (ws *WebSocket) writeFrame(opcode int, payload []byte, rsv1 bool) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+	if ws.closeSent || ws.IsClosed() {
+		return ErrWebSocketClosed
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+	frame := make([]byte, 2, len(payload)+10)
+	frame[0] = 0x80 | byte(opcode)
+	if rsv1 {
+		frame[0] |= 0x40
+	}
+	length := len(payload)
+	switch {
+	case length < 126:
+		frame[1] = byte(length)
+	case length <= 0xffff:
+		frame[1] = 126
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame[1] = 127
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+	if ws.config.WriteWait > 0 {
+		ws.conn.SetWriteDeadline(time.Now().Add(ws.config.WriteWait))
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// closeReceived reply the close frame and close the connection
+func (ws *WebSocket) closeReceived(payload []byte) error {
+	closeErr := &WebSocketCloseError{Code: CloseNoStatusReceived}
+	if len(payload) == 1 {
+		return ws.fail(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !isValidCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Text) {
+			return ws.fail(CloseProtocolError, "invalid close code or reason")
+		}
+	}
+	if closeErr.Code == CloseNoStatusReceived {
+		ws.writeFrame(CloseMessage, nil, false)
+		ws.closeConn()
+	} else {
+		ws.Close(closeErr.Code, "")
+	}
+	return closeErr
+}
+
+// fail close the connection with code, and return the close error
+func (ws *WebSocket) fail(code int, text string) error {
+	ws.Close(code, text)
+	return &WebSocketCloseError{Code: code, Text: text}
+}
+
+// readErr close the connection when read fails, e.g. read deadline exceeded
+func (ws *WebSocket) readErr(err error) error {
+	if ws.IsClosed() {
+		return ErrWebSocketClosed
+	}
+	ws.closeConn()
+	return err
+}
+
+func (ws *WebSocket) closeConn() {
+	if ws.closed.CompareAndSwap(false, true) {
+		close(ws.stop)
+		ws.conn.Close()
+	}
+}
+
+func (ws *WebSocket) pingLoop(interval time.Duration) {
+	defer ws.stopped.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.stop:
+			return
+		case <-ticker.C:
+			if ws.writeFrame(PingMessage, nil, false) != nil {
+				return
+			}
+		}
+	}
+}
+
+// inflateMessage decompress the message, the decompressed size is limited by MaxMessageSize
+func (ws *WebSocket) inflateMessage(data []byte) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail)))
+	defer fr.Close()
+	limit := ws.config.maxMessageSize()
+	out, err := io.ReadAll(io.LimitReader(fr, limit+1))
+	if err != nil {
+		return nil, ws.fail(CloseInvalidPayloadData, "invalid compressed data")
+	}
+	if int64(len(out)) > limit {
+		return nil, ws.fail(CloseMessageTooBig, "message too big")
+	}
+	return out, nil
+}
+
+// deflateMessage compress the message without context takeover, the sync flush tail is removed
+func deflateMessage(data []byte, level int) ([]byte, error) {
+	if level == 0 {
+		level = defaultWebSocketCompressionRate
+	}
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail[:4]), nil
+}
+
+func isValidCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func webSocketAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// checkSameOrigin allow request without Origin header or the Origin host is same as request host
+func checkSameOrigin(req *http.Request) bool {
+	origin := req.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Host)
+}
+
+// selectSubprotocol select the first subprotocol requested by client which is supported
+func selectSubprotocol(h http.Header, supported []string) string {
+	for _, protocol := range headerTokens(h, HeaderSecWebSocketProtocol) {
+		for _, s := range supported {
+			if protocol == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// acceptDeflate check client offers permessage-deflate which can be used with full window size
+func acceptDeflate(h http.Header) bool {
+	for _, offer := range headerTokens(h, HeaderSecWebSocketExtensions) {
+		params := strings.Split(offer, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), webSocketDeflateExtension) {
+			continue
+		}
+		accept := true
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "server_max_window_bits") && strings.Trim(value, `"`) != "15" {
+				accept = false
+			}
+		}
+		if accept {
+			return true
+		}
+	}
+	return false
+}
+
+// headerTokens split comma separated header values
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, token := range strings.Split(v, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package dotweb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+type testAuthMiddleware struct {
+	BaseMiddleware
+}
+
+func (m *testAuthMiddleware) Handle(ctx Context) error {
+	if ctx.QueryString("token") != "dotweb" {
+		return ctx.WriteStringC(http.StatusUnauthorized, "unauthorized")
+	}
+	return m.Next(ctx)
+}
+
+// testWebSocketClient is a minimal client which writes masked frames
+type testWebSocketClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialTestWebSocket(t *testing.T, server *httptest.Server, path string, header http.Header) (*testWebSocketClient, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	test.Nil(t, err)
+	req, _ := http.NewRequest("GET", server.URL+path, nil)
+	req.Header.Set(HeaderUpgrade, "websocket")
+	req.Header.Set(HeaderConnection, "Upgrade")
+	req.Header.Set(HeaderSecWebSocketKey, "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set(HeaderSecWebSocketVersion, "13")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	test.Nil(t, req.Write(conn))
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	test.Nil(t, err)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &testWebSocketClient{conn: conn, br: br}, res
+}
+
+func (c *testWebSocketClient) writeFrame(fin bool, opcode int, payload []byte, rsv1 bool) {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	frame := []byte{b0}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	c.conn.Write(frame)
+}
+
+func (c *testWebSocketClient) readFrame() (opcode int, payload []byte, rsv1 bool) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(c.br, head); err != nil {
+		return 0, nil, false
+	}
+	length := int(head[1] & 0x7f)
+	if length == 126 {
+		io.ReadFull(c.br, head)
+		length = int(binary.BigEndian.Uint16(head))
+	}
+	payload = make([]byte, length)
+	io.ReadFull(c.br, payload)
+	return int(head[0] & 0x0f), payload, head[0]&0x40 != 0
+}
+
+func (c *testWebSocketClient) readCloseCode() int {
+	for {
+		opcode, payload, _ := c.readFrame()
+		if opcode == 0 {
+			return 0
+		}
+		if opcode == CloseMessage && len(payload) >= 2 {
+			return int(binary.BigEndian.Uint16(payload))
+		}
+	}
+}
+
+func TestWebSocket_Echo(t *testing.T) {
+	app := New()
+	app.Use(&testHeaderMiddleware{})
+	app.HttpServer.SetWebSocketConfig(WebSocketConfig{Subprotocols: []string{"chat", "json"}})
+	app.HttpServer.WebSocket("/ws", func(ctx Context) error {
+		ws := ctx.WebSocket()
+		for {
+			messageType, data, err := ws.NextMessage()
+			if err != nil {
+				return nil
+			}
+			if err = ws.WriteMessage(messageType, data); err != nil {
+				return err
+			}
+		}
+	}).Use(&testAuthMiddleware{})
+	prepareTestApp(app)
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	// middleware run before upgrade
+	_, res := dialTestWebSocket(t, server, "/ws", nil)
+	test.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	c, res := dialTestWebSocket(t, server, "/ws?token=dotweb", http.Header{HeaderSecWebSocketProtocol: {"json, chat"}})
+	defer c.conn.Close()
+	test.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	test.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get(HeaderSecWebSocketAccept))
+	test.Equal(t, "json", res.Header.Get(HeaderSecWebSocketProtocol))
+	test.Equal(t, "1", res.Header.Get("X-Test-Middleware"))
+
+	c.writeFrame(true, TextMessage, []byte("hello"), false)
+	opcode, payload, _ := c.readFrame()
+	test.Equal(t, TextMessage, opcode)
+	test.Equal(t, "hello", string(payload))
+
+	c.writeFrame(true, BinaryMessage, []byte{0, 1, 2}, false)
+	opcode, payload, _ = c.readFrame()
+	test.Equal(t, BinaryMessage, opcode)
+	test.Equal(t, []byte{0, 1, 2}, payload)
+
+	// fragmented message with ping between
+	c.writeFrame(false, TextMessage, []byte("dot"), false)
+	c.writeFrame(true, PingMessage, []byte("p"), false)
+	c.writeFrame(true, continuationFrame, []byte("web"), false)
+	opcode, payload, _ = c.readFrame()
+	test.Equal(t, PongMessage, opcode)
+	test.Equal(t, "p", string(payload))
+	opcode, payload, _ = c.readFrame()
+	test.Equal(t, TextMessage, opcode)
+	test.Equal(t, "dotweb", string(payload))
+
+	c.writeFrame(true, CloseMessage, []byte{0x03, 0xe8}, false)
+	test.Equal(t, CloseNormalClosure, c.readCloseCode())
+}
+
+func TestWebSocket_Reject(t *testing.T) {
+	app := New()
+	app.HttpServer.SetWebSocketConfig(WebSocketConfig{MaxMessageSize: 8, PingInterval: 10 * time.Millisecond})
+	app.HttpServer.WebSocket("/ws", func(ctx Context) error {
+		_, _, err := ctx.WebSocket().NextMessage()
+		return err
+	})
+	prepareTestApp(app)
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	_, res := dialTestWebSocket(t, server, "/ws", http.Header{HeaderOrigin: {"http://evil.com"}})
+	test.Equal(t, http.StatusForbidden, res.StatusCode)
+	_, res = dialTestWebSocket(t, server, "/ws", http.Header{HeaderSecWebSocketVersion: {"8"}})
+	test.Equal(t, http.StatusUpgradeRequired, res.StatusCode)
+	w := doTestRequest(app, "GET", "/ws", nil)
+	test.Equal(t, http.StatusBadRequest, w.Code)
+
+	c, _ := dialTestWebSocket(t, server, "/ws", nil)
+	opcode, _, _ := c.readFrame()
+	test.Equal(t, PingMessage, opcode)
+	c.writeFrame(true, TextMessage, []byte("too long message"), false)
+	test.Equal(t, CloseMessageTooBig, c.readCloseCode())
+	c.conn.Close()
+
+	c, _ = dialTestWebSocket(t, server, "/ws", nil)
+	c.writeFrame(true, TextMessage, []byte{0xff, 0xfe}, false)
+	test.Equal(t, CloseInvalidPayloadData, c.readCloseCode())
+	c.conn.Close()
+
+	c, _ = dialTestWebSocket(t, server, "/ws", nil)
+	c.writeFrame(true, continuationFrame, []byte("x"), false)
+	test.Equal(t, CloseProtocolError, c.readCloseCode())
+	c.conn.Close()
+}
+
+func TestWebSocket_DefaultMaxMessageSize(t *testing.T) {
+	app := New()
+	// zero MaxMessageSize means the default limit, not unlimited
+	app.HttpServer.SetWebSocketConfig(WebSocketConfig{})
+	app.HttpServer.WebSocket("/ws", func(ctx Context) error {
+		_, _, err := ctx.WebSocket().NextMessage()
+		return err
+	})
+	prepareTestApp(app)
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	c, _ := dialTestWebSocket(t, server, "/ws", nil)
+	defer c.conn.Close()
+	// the header announces 1TB payload, which must be rejected before reading it
+	frame := []byte{0x80 | byte(BinaryMessage), 0x80 | 127}
+	frame = binary.BigEndian.AppendUint64(frame, 1<<40)
+	c.conn.Write(append(frame, 1, 2, 3, 4))
+	test.Equal(t, CloseMessageTooBig, c.readCloseCode())
+}
+
+func TestWebSocket_Compression(t *testing.T) {
+	app := New()
+	app.HttpServer.SetEnabledGzip(true)
+	app.HttpServer.SetWebSocketConfig(WebSocketConfig{EnableCompression: true})
+	app.HttpServer.WebSocket("/ws", func(ctx Context) error {
+		msg, err := ctx.WebSocket().ReadMessage()
+		if err != nil {
+			return err
+		}
+		return ctx.WebSocket().SendMessage(strings.ToUpper(msg))
+	})
+	prepareTestApp(app)
+	server := httptest.NewServer(app.HttpServer)
+	defer server.Close()
+
+	c, res := dialTestWebSocket(t, server, "/ws", http.Header{
+		HeaderSecWebSocketExtensions: {"permessage-deflate; client_max_window_bits"},
+		HeaderAcceptEncoding:         {"gzip"},
+	})
+	defer c.conn.Close()
+	test.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	test.Equal(t, "", res.Header.Get(HeaderContentEncoding))
+	test.Equal(t, true, strings.HasPrefix(res.Header.Get(HeaderSecWebSocketExtensions), "permessage-deflate"))
+
+	payload, err := deflateMessage([]byte(strings.Repeat("dotweb ", 20)), 0)
+	test.Nil(t, err)
+	c.writeFrame(true, TextMessage, payload, true)
+	opcode, payload, rsv1 := c.readFrame()
+	test.Equal(t, TextMessage, opcode)
+	test.Equal(t, true, rsv1)
+	data, err := io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail))))
+	test.Nil(t, err)
+	test.Equal(t, strings.Repeat("DOTWEB ", 20), string(data))
+	test.Equal(t, CloseNormalClosure, c.readCloseCode())
+}